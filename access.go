@@ -0,0 +1,216 @@
+package ecs
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// access is the component types a system reads and writes
+type access struct {
+	// Whether the system declared its access. Systems that haven't conflict with every system
+	declared bool
+	reads    map[ComponentTypeID]struct{}
+	writes   map[ComponentTypeID]struct{}
+}
+
+func newAccess(reads, writes []ComponentTypeID) access {
+	a := access{
+		declared: true,
+		reads:    make(map[ComponentTypeID]struct{}, len(reads)),
+		writes:   make(map[ComponentTypeID]struct{}, len(writes)),
+	}
+	for _, cType := range reads {
+		a.reads[cType] = struct{}{}
+	}
+	for _, cType := range writes {
+		a.writes[cType] = struct{}{}
+	}
+	return a
+}
+
+// Returns whether the component type can be read. Types that can be written can also be read
+func (a access) canRead(t ComponentTypeID) bool {
+	_, ok := a.reads[t]
+	return ok || a.canWrite(t)
+}
+
+func (a access) canWrite(t ComponentTypeID) bool {
+	_, ok := a.writes[t]
+	return ok
+}
+
+// Returns whether systems with the two accesses can't run at the same time, which is when either
+// writes a type the other reads or writes
+func (a access) conflicts(b access) bool {
+	if !a.declared || !b.declared {
+		return true
+	}
+	for cType := range a.writes {
+		if b.canRead(cType) {
+			return true
+		}
+	}
+	for cType := range b.writes {
+		if a.canRead(cType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the types in the set, or nil if the set is nil
+func accessTypes(set map[ComponentTypeID]struct{}) []ComponentTypeID {
+	if set == nil {
+		return nil
+	}
+	types := make([]ComponentTypeID, 0, len(set))
+	for cType := range set {
+		types = append(types, cType)
+	}
+	return types
+}
+
+// accessChecker panics when a system accesses a component type it didn't declare
+type accessChecker struct {
+	id     SystemID
+	access access
+}
+
+func (c accessChecker) read(t ComponentTypeID) {
+	if !c.access.canRead(t) {
+		panic(fmt.Errorf("system %d read component type %s without declaring it", c.id, t))
+	}
+}
+
+func (c accessChecker) write(t ComponentTypeID) {
+	if !c.access.canWrite(t) {
+		panic(fmt.Errorf("system %d wrote component type %s without declaring it", c.id, t))
+	}
+}
+
+// Wraps the entity so its components are checked
+func (c accessChecker) entity(e Entity) Entity {
+	if e.components != nil {
+		e.components = checkedEntityComponents{
+			entityComponents: e.components,
+			checker:          c,
+		}
+	}
+	return e
+}
+
+func (c accessChecker) entities(entities []Entity) []Entity {
+	for i := range entities {
+		entities[i] = c.entity(entities[i])
+	}
+	return entities
+}
+
+// Returns a copy of the engine that checks the types being accessed
+func (c accessChecker) ecs(ecs *ECS) *ECS {
+	checked := *ecs
+	checked.EntityComponentManager = checkedEntityComponentManager{
+		EntityComponentManager: ecs.EntityComponentManager,
+		checker:                c,
+	}
+	return &checked
+}
+
+// checkedEntityComponents is an entityComponents that checks the types being accessed
+type checkedEntityComponents struct {
+	entityComponents
+	checker accessChecker
+}
+
+func (c checkedEntityComponents) get(t ComponentTypeID) (Component, bool) {
+	c.checker.read(t)
+	return c.entityComponents.get(t)
+}
+
+func (c checkedEntityComponents) update(t ComponentTypeID, f func(data *interface{})) bool {
+	c.checker.write(t)
+	return c.entityComponents.update(t, f)
+}
+
+func (c checkedEntityComponents) all() map[ComponentTypeID]Component {
+	components := c.entityComponents.all()
+	for cType := range components {
+		c.checker.read(cType)
+	}
+	return components
+}
+
+func (c checkedEntityComponents) ticks(t ComponentTypeID) (ComponentTicks, bool) {
+	c.checker.read(t)
+	return c.entityComponents.ticks(t)
+}
+
+// checkedEntityComponentManager is an EntityComponentManager that checks the types being accessed,
+// both directly and through the entities it returns
+type checkedEntityComponentManager struct {
+	EntityComponentManager
+	checker accessChecker
+}
+
+func (m checkedEntityComponentManager) NewComponent(
+	eID EntityID, cType ComponentTypeID, data interface{}) (ComponentID, error) {
+	m.checker.write(cType)
+	return m.EntityComponentManager.NewComponent(eID, cType, data)
+}
+
+func (m checkedEntityComponentManager) NewComponentReflect(
+	eID EntityID, data interface{}) (ComponentID, error) {
+	return m.NewComponent(eID, reflect.TypeOf(data), data)
+}
+
+func (m checkedEntityComponentManager) ForEntities(i func(Entity) (bool, error)) (bool, error) {
+	return m.EntityComponentManager.ForEntities(func(e Entity) (bool, error) {
+		return i(m.checker.entity(e))
+	})
+}
+
+func (m checkedEntityComponentManager) GetEntity(id EntityID) Entity {
+	return m.checker.entity(m.EntityComponentManager.GetEntity(id))
+}
+
+func (m checkedEntityComponentManager) GetEntitySafe(id EntityID) (Entity, bool) {
+	e, ok := m.EntityComponentManager.GetEntitySafe(id)
+	return m.checker.entity(e), ok
+}
+
+func (m checkedEntityComponentManager) GetEntities(actsOn []ComponentTypeID) []Entity {
+	return m.checker.entities(m.EntityComponentManager.GetEntities(actsOn))
+}
+
+func (m checkedEntityComponentManager) QueryEntities(q Query) []Entity {
+	return m.checker.entities(m.EntityComponentManager.QueryEntities(q))
+}
+
+func (m checkedEntityComponentManager) GetComponent(id ComponentID) interface{} {
+	m.checker.read(id.ComponentTypeID)
+	return m.EntityComponentManager.GetComponent(id)
+}
+
+func (m checkedEntityComponentManager) UpdateComponent(id ComponentID, data interface{}) {
+	m.checker.write(id.ComponentTypeID)
+	m.EntityComponentManager.UpdateComponent(id, data)
+}
+
+func (m checkedEntityComponentManager) UpdateComponentFunc(
+	id ComponentID, f func(data *interface{})) {
+	m.checker.write(id.ComponentTypeID)
+	m.EntityComponentManager.UpdateComponentFunc(id, f)
+}
+
+// DeleteEntity checks the entity's components can all be written, as they are all deleted
+func (m checkedEntityComponentManager) DeleteEntity(id EntityID) {
+	for cType := range m.EntityComponentManager.GetEntity(id).Components() {
+		m.checker.write(cType)
+	}
+	m.EntityComponentManager.DeleteEntity(id)
+}
+
+func (m checkedEntityComponentManager) DeleteComponent(id ComponentID) {
+	m.checker.write(id.ComponentTypeID)
+	m.EntityComponentManager.DeleteComponent(id)
+}
@@ -0,0 +1,122 @@
+package ecs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAccess_conflicts(t *testing.T) {
+	a := assert.New(t)
+
+	read1 := newAccess([]ComponentTypeID{componentType1}, nil)
+	write1 := newAccess(nil, []ComponentTypeID{componentType1})
+	write2 := newAccess([]ComponentTypeID{componentType1}, []ComponentTypeID{componentType2})
+
+	a.False(read1.conflicts(read1))
+	a.True(read1.conflicts(write1))
+	a.True(write1.conflicts(read1))
+	a.True(write1.conflicts(write1))
+	a.False(read1.conflicts(write2))
+	a.True(write1.conflicts(write2))
+
+	// Systems that haven't declared their access conflict with everything
+	a.True(access{}.conflicts(read1))
+	a.True(read1.conflicts(access{}))
+}
+
+func TestSystemManager_parallelBatches(t *testing.T) {
+	a := assert.New(t)
+	m, ids, ran := newScheduleTestSystems(6)
+
+	m.SetSystemAccess(ids[0], []ComponentTypeID{componentType1}, nil)
+	m.SetSystemAccess(ids[1], []ComponentTypeID{componentType1}, []ComponentTypeID{componentType2})
+	m.SetSystemAccess(ids[2], nil, []ComponentTypeID{componentType1})
+	m.SetSystemAccess(ids[3], []ComponentTypeID{componentType3}, nil)
+	m.SetSystemAccess(ids[5], []ComponentTypeID{componentType3}, nil)
+	a.Equal([]ComponentTypeID{componentType1}, m.GetSystem(ids[1]).Reads())
+	a.Equal([]ComponentTypeID{componentType2}, m.GetSystem(ids[1]).Writes())
+	a.Nil(m.GetSystem(ids[4]).Reads())
+
+	// 3 has to wait for 1, even though they don't conflict, which puts it last in the schedule
+	a.NoError(m.SystemBefore(ids[1], ids[3]))
+	// 4 hasn't declared its access, so it runs on its own
+	a.Equal([][]SystemID{{ids[0], ids[1]}, {ids[2]}, {ids[4]}, {ids[5], ids[3]}},
+		m.parallelBatches(EventType1))
+
+	// Systems in a later stage wait for the whole of the earlier stage
+	a.NoError(m.SetSystemStage(ids[4], Render))
+	a.Equal([][]SystemID{{ids[0], ids[1], ids[5]}, {ids[2], ids[3]}, {ids[4]}},
+		m.parallelBatches(EventType1))
+
+	m.RunSystemsParallel(Event{EventTypeID: EventType1})
+	a.ElementsMatch([]SystemID{ids[0], ids[1], ids[5]}, (*ran)[:3])
+	a.ElementsMatch([]SystemID{ids[2], ids[3]}, (*ran)[3:5])
+	a.Equal(ids[4], (*ran)[5])
+
+	// Other events don't run any systems
+	a.Empty(m.parallelBatches(componentType2))
+}
+
+func TestSystemManager_SetAccessChecks(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, em EntityComponentManager) {
+		ecs := &ECS{
+			EntityComponentManager: em,
+		}
+		m := newSystemManager(ecs)
+		m.SetAccessChecks(true)
+
+		entityID := ecs.NewEntity("entity")
+		componentID, err := newComponent1(ecs, entityID)
+		a.NoError(err)
+		_, err = newComponent2(ecs, entityID)
+		a.NoError(err)
+		// An entity with only components the system can write
+		writableID := ecs.NewEntity("writable")
+		_, err = newComponent1(ecs, writableID)
+		a.NoError(err)
+
+		var f func(*ECS, Entity)
+		id := m.NewSystem(func(ecs *ECS, _ Event, entity Entity) {
+			f(ecs, entity)
+		}, EventType1, []ComponentTypeID{componentType1})
+		m.SetSystemAccess(id, []ComponentTypeID{componentType2}, []ComponentTypeID{componentType1})
+
+		for _, test := range []struct {
+			name   string
+			f      func(*ECS, Entity)
+			panics bool
+		}{
+			{"read", func(_ *ECS, e Entity) { e.Get(componentType2) }, false},
+			{"write", func(_ *ECS, e Entity) {
+				e.UpdateFunc(componentType1, func(*interface{}) {})
+			}, false},
+			{"read written", func(ecs *ECS, _ Entity) { ecs.GetComponent(componentID) }, false},
+			{"has", func(_ *ECS, e Entity) { e.Has(componentType3) }, false},
+			{"undeclared read", func(_ *ECS, e Entity) { e.Get(componentType3) }, true},
+			{"undeclared write", func(_ *ECS, e Entity) {
+				e.UpdateFunc(componentType2, func(*interface{}) {})
+			}, true},
+			{"other entity", func(ecs *ECS, _ Entity) {
+				ecs.GetEntity(ecs.NewEntity("other")).Get(componentType3)
+			}, true},
+			{"new component", func(ecs *ECS, e Entity) {
+				_, _ = newComponent3(ecs, e.ID())
+			}, true},
+			{"delete entity", func(ecs *ECS, _ Entity) { ecs.DeleteEntity(writableID) }, false},
+			{"delete undeclared entity", func(ecs *ECS, e Entity) { ecs.DeleteEntity(e.ID()) },
+				true},
+		} {
+			// The access checks panic, which is recovered as an error
+			f = test.f
+			var panicErr *PanicError
+			err := m.RunSystems(Event{EventTypeID: EventType1})
+			a.Equal(test.panics, errors.As(err, &panicErr), test.name)
+		}
+
+		// Without access checks nothing should fail
+		m.SetAccessChecks(false)
+		f = func(_ *ECS, e Entity) { e.Get(componentType3) }
+		a.NoError(m.RunSystems(Event{EventTypeID: EventType1}))
+	})
+}
@@ -0,0 +1,778 @@
+package ecs
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// column is all the components of a single type in an archetype. Each row is an entity
+type column struct {
+	ids   []ComponentID
+	data  []interface{}
+	ticks []ComponentTicks
+}
+
+// archetype stores all the entities with exactly the same set of component types, with each
+// component type in its own column
+type archetype struct {
+	types    []ComponentTypeID
+	entities []EntityID
+	columns  map[ComponentTypeID]*column
+
+	// The archetypes with a component type added or removed, so they don't need to be searched for
+	// every time an entity moves
+	with    map[ComponentTypeID]*archetype
+	without map[ComponentTypeID]*archetype
+}
+
+func newArchetype(types []ComponentTypeID) *archetype {
+	a := &archetype{
+		types:    types,
+		entities: make([]EntityID, 0),
+		columns:  make(map[ComponentTypeID]*column, len(types)),
+		with:     make(map[ComponentTypeID]*archetype),
+		without:  make(map[ComponentTypeID]*archetype),
+	}
+	for _, cType := range types {
+		a.columns[cType] = &column{
+			ids:   make([]ComponentID, 0),
+			data:  make([]interface{}, 0),
+			ticks: make([]ComponentTicks, 0),
+		}
+	}
+	return a
+}
+
+func (a *archetype) has(t ComponentTypeID) bool {
+	_, ok := a.columns[t]
+	return ok
+}
+
+// Returns whether the archetype has all the given component types
+func (a *archetype) hasAll(types []ComponentTypeID) bool {
+	for _, cType := range types {
+		if !a.has(cType) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether the archetype has exactly the given component types
+func (a *archetype) is(types []ComponentTypeID) bool {
+	return len(a.types) == len(types) && a.hasAll(types)
+}
+
+// Adds a row for the given entity and returns it. The columns of the row are left empty
+func (a *archetype) add(eID EntityID) int {
+	a.entities = append(a.entities, eID)
+	for _, c := range a.columns {
+		c.ids = append(c.ids, ComponentID{})
+		c.data = append(c.data, nil)
+		c.ticks = append(c.ticks, ComponentTicks{})
+	}
+	return len(a.entities) - 1
+}
+
+// Removes the given row by moving the last row into its place. Returns the ID of the moved entity
+// and whether an entity was actually moved
+func (a *archetype) remove(row int) (EntityID, bool) {
+	last := len(a.entities) - 1
+	moved := a.entities[last]
+	a.entities[row] = moved
+	a.entities = a.entities[:last]
+	for _, c := range a.columns {
+		c.ids[row] = c.ids[last]
+		c.data[row] = c.data[last]
+		c.ticks[row] = c.ticks[last]
+		// Don't keep the data alive
+		c.data[last] = nil
+		c.ids = c.ids[:last]
+		c.data = c.data[:last]
+		c.ticks = c.ticks[:last]
+	}
+	return moved, row != last
+}
+
+type archetypeEntity struct {
+	name       string
+	generation uint32
+	deleted    bool
+	archetype  *archetype
+	row        int
+	// The change tick each component type was last removed at
+	removed map[ComponentTypeID]uint64
+}
+
+type componentSlot struct {
+	entity     EntityID
+	generation uint32
+	deleted    bool
+}
+
+// componentSlots maps the IDs of all the components of a given type to their entities
+type componentSlots struct {
+	slots []componentSlot
+	// The IDs of deleted components, which will be reused before slots grows
+	free []int
+}
+
+func (s *componentSlots) new(eID EntityID) (int, uint32) {
+	// If there is a deleted component that can be reused
+	if len(s.free) > 0 {
+		id := s.free[len(s.free)-1]
+		s.free = s.free[:len(s.free)-1]
+		generation := s.slots[id].generation + 1
+		s.slots[id] = componentSlot{
+			entity:     eID,
+			generation: generation,
+		}
+		return id, generation
+	}
+	s.slots = append(s.slots, componentSlot{entity: eID})
+	return len(s.slots) - 1, 0
+}
+
+func (s *componentSlots) getSafe(id ComponentID) (EntityID, bool) {
+	if id.ID < 0 || id.ID >= len(s.slots) {
+		return 0, false
+	}
+	slot := s.slots[id.ID]
+	if slot.deleted || slot.generation != id.Generation {
+		return 0, false
+	}
+	return slot.entity, true
+}
+
+func (s *componentSlots) delete(id int) {
+	s.slots[id].deleted = true
+	s.free = append(s.free, id)
+}
+
+// archetypeEntityComponents is the entityComponents of an entity stored by
+// archetypeEntityComponentManager. As entities move between archetypes, the components are looked
+// up every time they're accessed
+type archetypeEntityComponents struct {
+	m  *archetypeEntityComponentManager
+	id EntityID
+}
+
+func (c archetypeEntityComponents) has(t ComponentTypeID) bool {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	if !c.m.isAlive(c.id) {
+		return false
+	}
+	return c.m.entities[c.id.Index()].archetype.has(t)
+}
+
+func (c archetypeEntityComponents) get(t ComponentTypeID) (Component, bool) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	if !c.m.isAlive(c.id) {
+		return Component{}, false
+	}
+	e := c.m.entities[c.id.Index()]
+	col, ok := e.archetype.columns[t]
+	if !ok {
+		return Component{}, false
+	}
+	return Component{
+		id:   col.ids[e.row],
+		Data: col.data[e.row],
+	}, true
+}
+
+func (c archetypeEntityComponents) update(t ComponentTypeID, f func(data *interface{})) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if !c.m.isAlive(c.id) {
+		return false
+	}
+	e := c.m.entities[c.id.Index()]
+	col, ok := e.archetype.columns[t]
+	if !ok {
+		return false
+	}
+	f(&col.data[e.row])
+	col.ticks[e.row].Changed = c.m.tick.Load()
+	return true
+}
+
+func (c archetypeEntityComponents) all() map[ComponentTypeID]Component {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	if !c.m.isAlive(c.id) {
+		return map[ComponentTypeID]Component{}
+	}
+	e := c.m.entities[c.id.Index()]
+	components := make(map[ComponentTypeID]Component, len(e.archetype.columns))
+	for cType, col := range e.archetype.columns {
+		components[cType] = Component{
+			id:   col.ids[e.row],
+			Data: col.data[e.row],
+		}
+	}
+	return components
+}
+
+func (c archetypeEntityComponents) ticks(t ComponentTypeID) (ComponentTicks, bool) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	if !c.m.isAlive(c.id) {
+		return ComponentTicks{}, false
+	}
+	e := c.m.entities[c.id.Index()]
+	col, ok := e.archetype.columns[t]
+	if !ok {
+		return ComponentTicks{}, false
+	}
+	return col.ticks[e.row], true
+}
+
+func (c archetypeEntityComponents) removed(t ComponentTypeID) (uint64, bool) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	if !c.m.isAlive(c.id) {
+		return 0, false
+	}
+	tick, ok := c.m.entities[c.id.Index()].removed[t]
+	return tick, ok
+}
+
+// archetypeEntityComponentManager is an EntityComponentManager that groups entities by their
+// archetype, so queries only need to check each archetype rather than each entity
+type archetypeEntityComponentManager struct {
+	// The manager mutex, which protects everything
+	sync.RWMutex
+
+	// The archetype with no component types, which all new entities start in
+	root       *archetype
+	archetypes []*archetype
+	components map[ComponentTypeID]*componentSlots
+
+	entities []archetypeEntity
+	// The indices of deleted entities, which will be reused before entities grows
+	freeEntities       []int
+	entitiesToBeKilled map[EntityID]struct{}
+
+	newComponentCallbacks    []ComponentCallback
+	deleteComponentCallbacks []ComponentCallback
+
+	tick atomic.Uint64
+}
+
+func newArchetypeEntityComponentManager() *archetypeEntityComponentManager {
+	root := newArchetype(nil)
+	m := &archetypeEntityComponentManager{
+		root:       root,
+		archetypes: []*archetype{root},
+		components: make(map[ComponentTypeID]*componentSlots),
+
+		entities:           make([]archetypeEntity, 0),
+		freeEntities:       make([]int, 0),
+		entitiesToBeKilled: make(map[EntityID]struct{}),
+
+		newComponentCallbacks:    make([]ComponentCallback, 0),
+		deleteComponentCallbacks: make([]ComponentCallback, 0),
+	}
+	// Start after 0, so components created before a system first runs count as added
+	m.tick.Store(1)
+	return m
+}
+
+// NewArchetypeEntityComponentManager creates and returns an entity component manager that stores
+// entities grouped by their archetype (the set of component types they have), with each component
+// type in its own column. This makes getting entities by their component types faster, but adding
+// and deleting components slower as the entity has to move to a different archetype
+func NewArchetypeEntityComponentManager() EntityComponentManager {
+	return newArchetypeEntityComponentManager()
+}
+
+// Returns the archetype with exactly the given component types, creating it if it doesn't exist.
+// Doesn't lock the mutex
+func (m *archetypeEntityComponentManager) getArchetype(types []ComponentTypeID) *archetype {
+	for _, a := range m.archetypes {
+		if a.is(types) {
+			return a
+		}
+	}
+	a := newArchetype(types)
+	m.archetypes = append(m.archetypes, a)
+	return a
+}
+
+// Returns the archetype with the types of a and the given type. Doesn't lock the mutex
+func (m *archetypeEntityComponentManager) archetypeWith(
+	a *archetype, t ComponentTypeID) *archetype {
+	next, ok := a.with[t]
+	if !ok {
+		types := make([]ComponentTypeID, 0, len(a.types)+1)
+		types = append(types, a.types...)
+		types = append(types, t)
+		next = m.getArchetype(types)
+		a.with[t] = next
+		next.without[t] = a
+	}
+	return next
+}
+
+// Returns the archetype with the types of a except the given type. Doesn't lock the mutex
+func (m *archetypeEntityComponentManager) archetypeWithout(
+	a *archetype, t ComponentTypeID) *archetype {
+	next, ok := a.without[t]
+	if !ok {
+		types := make([]ComponentTypeID, 0, len(a.types))
+		for _, cType := range a.types {
+			if cType != t {
+				types = append(types, cType)
+			}
+		}
+		next = m.getArchetype(types)
+		a.without[t] = next
+		next.with[t] = a
+	}
+	return next
+}
+
+// Moves the entity at the given index to the given archetype, keeping the components both
+// archetypes have. Returns the entity's new row. Doesn't lock the mutex
+func (m *archetypeEntityComponentManager) move(index int, dest *archetype) int {
+	e := &m.entities[index]
+	src, srcRow := e.archetype, e.row
+	// The entity is already in the archetype, for example when an entity without components is
+	// deleted
+	if src == dest {
+		return srcRow
+	}
+
+	row := dest.add(m.entityID(index))
+	for cType, c := range dest.columns {
+		if srcColumn, ok := src.columns[cType]; ok {
+			c.ids[row] = srcColumn.ids[srcRow]
+			c.data[row] = srcColumn.data[srcRow]
+			c.ticks[row] = srcColumn.ticks[srcRow]
+		}
+	}
+
+	// Remove the entity from the old archetype, and fix the row of the entity that took its place
+	moved, ok := src.remove(srcRow)
+	if ok {
+		m.entities[moved.Index()].row = srcRow
+	}
+
+	e.archetype = dest
+	e.row = row
+	return row
+}
+
+// Records that the component type was removed from the entity at the given index at the current
+// change tick. Doesn't lock the mutex
+func (m *archetypeEntityComponentManager) setRemoved(index int, t ComponentTypeID) {
+	e := &m.entities[index]
+	if e.removed == nil {
+		e.removed = make(map[ComponentTypeID]uint64)
+	}
+	e.removed[t] = m.tick.Load()
+}
+
+// Returns the ID of the entity at the given index. Doesn't lock the mutex
+func (m *archetypeEntityComponentManager) entityID(index int) EntityID {
+	return newEntityID(index, m.entities[index].generation)
+}
+
+// Returns whether the entity is alive. Doesn't lock the mutex
+func (m *archetypeEntityComponentManager) isAlive(id EntityID) bool {
+	index := id.Index()
+	return index < len(m.entities) &&
+		!m.entities[index].deleted &&
+		m.entities[index].generation == id.Generation()
+}
+
+// Creates an Entity from the given id and name. Doesn't lock the mutex
+func (m *archetypeEntityComponentManager) newEntity(id EntityID, name string) Entity {
+	return Entity{
+		id:         id,
+		name:       name,
+		components: archetypeEntityComponents{m: m, id: id},
+	}
+}
+
+// Returns the column and row of the given component. Doesn't lock the mutex
+func (m *archetypeEntityComponentManager) getColumn(id ComponentID) (*column, int, bool) {
+	slots, ok := m.components[id.ComponentTypeID]
+	if !ok {
+		return nil, 0, false
+	}
+	eID, ok := slots.getSafe(id)
+	if !ok {
+		return nil, 0, false
+	}
+	e := m.entities[eID.Index()]
+	return e.archetype.columns[id.ComponentTypeID], e.row, true
+}
+
+func (m *archetypeEntityComponentManager) NewEntity(name string) EntityID {
+	m.Lock()
+	defer m.Unlock()
+
+	var id EntityID
+	// If there is a deleted entity that can be reused
+	if len(m.freeEntities) > 0 {
+		index := m.freeEntities[len(m.freeEntities)-1]
+		m.freeEntities = m.freeEntities[:len(m.freeEntities)-1]
+		id = newEntityID(index, m.entities[index].generation+1)
+		m.entities[index] = archetypeEntity{
+			name:       name,
+			generation: id.Generation(),
+		}
+	} else {
+		id = newEntityID(len(m.entities), 0)
+		m.entities = append(m.entities, archetypeEntity{
+			name: name,
+		})
+	}
+	m.entities[id.Index()].archetype = m.root
+	m.entities[id.Index()].row = m.root.add(id)
+
+	// The entity is empty, so it will be killed (if it isn't given a component)
+	m.entitiesToBeKilled[id] = struct{}{}
+	return id
+}
+
+func (m *archetypeEntityComponentManager) NewComponent(eID EntityID,
+	cType ComponentTypeID, data interface{}) (ComponentID, error) {
+	// Call the code in an anonymous function so the mutex unlocks early
+	id, name, err := func() (ComponentID, string, error) {
+		m.Lock()
+		defer m.Unlock()
+
+		if !m.isAlive(eID) {
+			return ComponentID{}, "", fmt.Errorf("entity %d is not alive", eID)
+		}
+
+		// Check for duplicate types
+		e := m.entities[eID.Index()]
+		if e.archetype.has(cType) {
+			return ComponentID{}, e.name, fmt.Errorf(
+				"two components of the same type (%s) in entity %d", cType.String(), eID)
+		}
+
+		slots, ok := m.components[cType]
+		if !ok {
+			slots = &componentSlots{
+				slots: make([]componentSlot, 0),
+				free:  make([]int, 0),
+			}
+			m.components[cType] = slots
+		}
+
+		// Create the component
+		cID, generation := slots.new(eID)
+		id := ComponentID{
+			ID:              cID,
+			ComponentTypeID: cType,
+			Generation:      generation,
+		}
+
+		// Move the entity to its new archetype and add the component to it
+		dest := m.archetypeWith(e.archetype, cType)
+		row := m.move(eID.Index(), dest)
+		dest.columns[cType].ids[row] = id
+		dest.columns[cType].data[row] = data
+		tick := m.tick.Load()
+		dest.columns[cType].ticks[row] = ComponentTicks{Added: tick, Changed: tick}
+
+		// Make sure the entity won't be deleted
+		delete(m.entitiesToBeKilled, eID)
+
+		return id, e.name, nil
+	}()
+	if err != nil {
+		return id, err
+	}
+
+	// Run the callbacks
+	for _, callback := range m.newComponentCallbacks {
+		callback(m.newEntity(eID, name))
+	}
+
+	// Return the id
+	return id, nil
+}
+
+func (m *archetypeEntityComponentManager) NewComponentReflect(
+	eID EntityID, data interface{}) (ComponentID, error) {
+	return m.NewComponent(eID, reflect.TypeOf(data), data)
+}
+
+func (m *archetypeEntityComponentManager) NewComponentCallback(c ComponentCallback) {
+	m.newComponentCallbacks = append(m.newComponentCallbacks, c)
+}
+
+func (m *archetypeEntityComponentManager) ForEntities(
+	i func(Entity) (bool, error)) (bool, error) {
+	m.RLock()
+	for index := 0; index < len(m.entities); index++ {
+		if m.entities[index].deleted {
+			continue
+		}
+		e := m.newEntity(m.entityID(index), m.entities[index].name)
+		m.RUnlock()
+		ok, err := i(e)
+		if !ok || err != nil {
+			return ok, err
+		}
+		m.RLock()
+	}
+	m.RUnlock()
+	return true, nil
+}
+
+func (m *archetypeEntityComponentManager) IsAlive(id EntityID) bool {
+	m.RLock()
+	defer m.RUnlock()
+	return m.isAlive(id)
+}
+
+func (m *archetypeEntityComponentManager) GetEntity(id EntityID) Entity {
+	e, _ := m.GetEntitySafe(id)
+	return e
+}
+
+func (m *archetypeEntityComponentManager) GetEntitySafe(id EntityID) (Entity, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	if !m.isAlive(id) {
+		return Entity{id: id}, false
+	}
+	return m.newEntity(id, m.entities[id.Index()].name), true
+}
+
+func (m *archetypeEntityComponentManager) GetEntityIDs(actsOn []ComponentTypeID) []EntityID {
+	return m.QueryEntityIDs(NewQuery().With(actsOn...))
+}
+
+func (m *archetypeEntityComponentManager) GetEntities(actsOn []ComponentTypeID) []Entity {
+	return m.QueryEntities(NewQuery().With(actsOn...))
+}
+
+func (m *archetypeEntityComponentManager) QueryEntityIDs(q Query) []EntityID {
+	// Checking the change filters needs the entities' components, which lock the mutex
+	if q.hasChangeFilters() {
+		entities := m.QueryEntities(q)
+		ids := make([]EntityID, 0, len(entities))
+		for _, e := range entities {
+			ids = append(ids, e.ID())
+		}
+		return ids
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	entities := make([]EntityID, 0)
+
+	// Only the archetypes need to be checked, rather than every entity
+	for _, a := range m.archetypes {
+		if q.matches(a.has) {
+			entities = append(entities, a.entities...)
+		}
+	}
+
+	return entities
+}
+
+func (m *archetypeEntityComponentManager) QueryEntities(q Query) []Entity {
+	// Get the entities in an anonymous function so the mutex unlocks before the change filters are
+	// checked
+	entities := func() []Entity {
+		m.RLock()
+		defer m.RUnlock()
+
+		entities := make([]Entity, 0)
+
+		for _, a := range m.archetypes {
+			if q.matches(a.has) {
+				for _, eID := range a.entities {
+					entities = append(entities, m.newEntity(eID, m.entities[eID.Index()].name))
+				}
+			}
+		}
+
+		return entities
+	}()
+
+	return q.filterChanges(entities)
+}
+
+func (m *archetypeEntityComponentManager) GetComponent(id ComponentID) interface{} {
+	m.RLock()
+	defer m.RUnlock()
+	c, row, ok := m.getColumn(id)
+	if !ok {
+		return nil
+	}
+	return c.data[row]
+}
+
+func (m *archetypeEntityComponentManager) UpdateComponent(id ComponentID, data interface{}) {
+	m.Lock()
+	defer m.Unlock()
+	c, row, ok := m.getColumn(id)
+	if !ok {
+		return
+	}
+	c.data[row] = data
+	c.ticks[row].Changed = m.tick.Load()
+}
+
+func (m *archetypeEntityComponentManager) UpdateComponentFunc(
+	id ComponentID, f func(data *interface{})) {
+	m.Lock()
+	defer m.Unlock()
+	c, row, ok := m.getColumn(id)
+	if !ok {
+		return
+	}
+	f(&c.data[row])
+	c.ticks[row].Changed = m.tick.Load()
+}
+
+func (m *archetypeEntityComponentManager) DeleteEntity(id EntityID) {
+	name, deleted := func() (string, bool) {
+		m.Lock()
+		defer m.Unlock()
+
+		if !m.isAlive(id) {
+			return "", false
+		}
+
+		// Delete the entity's components
+		e := m.entities[id.Index()]
+		for cType, c := range e.archetype.columns {
+			m.components[cType].delete(c.ids[e.row].ID)
+			m.setRemoved(id.Index(), cType)
+		}
+		m.move(id.Index(), m.root)
+
+		// Set the entity has to be killed
+		m.entitiesToBeKilled[id] = struct{}{}
+
+		return e.name, true
+	}()
+
+	// If the entity was actually deleted
+	if deleted {
+		// Run the callbacks, so anything tracking the entity knows it has no components
+		for _, callback := range m.deleteComponentCallbacks {
+			callback(m.newEntity(id, name))
+		}
+	}
+}
+
+func (m *archetypeEntityComponentManager) DeleteComponent(id ComponentID) {
+	eID, name, deleted := func() (EntityID, string, bool) {
+		m.Lock()
+		defer m.Unlock()
+
+		slots, ok := m.components[id.ComponentTypeID]
+		if !ok {
+			return 0, "", false
+		}
+
+		// Get the component's entity
+		eID, ok := slots.getSafe(id)
+		if !ok {
+			return 0, "", false
+		}
+
+		// Delete the component
+		slots.delete(id.ID)
+
+		// Move the entity to the archetype without the component
+		m.setRemoved(eID.Index(), id.ComponentTypeID)
+		e := m.entities[eID.Index()]
+		dest := m.archetypeWithout(e.archetype, id.ComponentTypeID)
+		m.move(eID.Index(), dest)
+
+		// If the entity is now empty
+		if dest == m.root {
+			// Kill it
+			m.entitiesToBeKilled[eID] = struct{}{}
+		}
+
+		return eID, e.name, true
+	}()
+
+	// If the component was actually deleted
+	if deleted {
+		// Run the callbacks
+		for _, callback := range m.deleteComponentCallbacks {
+			callback(m.newEntity(eID, name))
+		}
+	}
+}
+
+func (m *archetypeEntityComponentManager) DeleteComponentCallback(c ComponentCallback) {
+	m.deleteComponentCallbacks = append(m.deleteComponentCallbacks, c)
+}
+
+func (m *archetypeEntityComponentManager) DeleteEmptyEntities() {
+	m.Lock()
+	defer m.Unlock()
+
+	// In order, so the indices are reused in the same order every time
+	for _, id := range sortedEntityIDs(m.entitiesToBeKilled) {
+		delete(m.entitiesToBeKilled, id)
+		if !m.isAlive(id) {
+			continue
+		}
+
+		// Remove the entity from its archetype
+		e := &m.entities[id.Index()]
+		moved, ok := e.archetype.remove(e.row)
+		if ok {
+			m.entities[moved.Index()].row = e.row
+		}
+
+		// Delete the entity, and let its index be reused
+		e.deleted = true
+		e.archetype = nil
+		m.freeEntities = append(m.freeEntities, id.Index())
+	}
+}
+
+func (m *archetypeEntityComponentManager) Stats() Stats {
+	m.RLock()
+	defer m.RUnlock()
+
+	stats := Stats{
+		Entities: SlotStats{
+			Live:     len(m.entities) - len(m.freeEntities),
+			Free:     len(m.freeEntities),
+			Capacity: len(m.entities),
+		},
+		Components: make(map[ComponentTypeID]SlotStats, len(m.components)),
+	}
+
+	for cType, slots := range m.components {
+		stats.Components[cType] = SlotStats{
+			Live:     len(slots.slots) - len(slots.free),
+			Free:     len(slots.free),
+			Capacity: len(slots.slots),
+		}
+	}
+
+	return stats
+}
+
+func (m *archetypeEntityComponentManager) ChangeTick() uint64 {
+	return m.tick.Load()
+}
+
+func (m *archetypeEntityComponentManager) AdvanceChangeTick() uint64 {
+	return m.tick.Add(1)
+}
@@ -0,0 +1,98 @@
+package ecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestArchetypeEntityComponentManager_NewComponent(t *testing.T) {
+	a := assert.New(t)
+	m := newArchetypeEntityComponentManager()
+
+	entityID := m.NewEntity("entity")
+	a.Equal(m.root, m.entities[entityID].archetype)
+	a.Equal([]EntityID{entityID}, m.root.entities)
+
+	id1, err := newComponent1(m, entityID)
+	a.NoError(err)
+	id2, err := newComponent2(m, entityID)
+	a.NoError(err)
+
+	// The entity should have moved to the archetype with both component types
+	arch := m.entities[entityID].archetype
+	a.True(arch.is([]ComponentTypeID{componentType1, componentType2}))
+	a.Equal([]EntityID{entityID}, arch.entities)
+	a.Equal([]ComponentID{id1}, arch.columns[componentType1].ids)
+	a.Equal([]interface{}{component1Value}, arch.columns[componentType1].data)
+	a.Equal([]ComponentID{id2}, arch.columns[componentType2].ids)
+	a.Equal([]interface{}{component2Value}, arch.columns[componentType2].data)
+
+	// The archetypes it passed through should be empty
+	a.Len(m.root.entities, 0)
+	a.Len(m.root.with[componentType1].entities, 0)
+
+	// An entity with the same types in a different order should be in the same archetype
+	entityID2 := m.NewEntity("entity")
+	_, err = newComponent2(m, entityID2)
+	a.NoError(err)
+	_, err = newComponent1(m, entityID2)
+	a.NoError(err)
+	a.Equal(arch, m.entities[entityID2].archetype)
+	a.Len(m.archetypes, 4)
+}
+
+func TestArchetypeEntityComponentManager_DeleteComponent(t *testing.T) {
+	a := assert.New(t)
+	m := newArchetypeEntityComponentManager()
+
+	entityID1 := m.NewEntity("entity")
+	id, err := newComponent1(m, entityID1)
+	a.NoError(err)
+
+	entityID2 := m.NewEntity("entity")
+	_, err = newComponent1(m, entityID2)
+	a.NoError(err)
+
+	arch := m.entities[entityID1].archetype
+	a.Equal([]EntityID{entityID1, entityID2}, arch.entities)
+
+	m.DeleteComponent(id)
+
+	// The last entity should have been moved into the deleted entity's row
+	a.Equal([]EntityID{entityID2}, arch.entities)
+	a.Equal(0, m.entities[entityID2].row)
+	a.Equal(component1Value, m.GetEntity(entityID2).Get(componentType1).Data)
+
+	// The entity should be back in the root archetype, and killed
+	a.Equal(m.root, m.entities[entityID1].archetype)
+	_, ok := m.entitiesToBeKilled[entityID1]
+	a.True(ok)
+
+	m.DeleteEmptyEntities()
+	a.Len(m.root.entities, 0)
+	a.True(m.entities[entityID1].deleted)
+	a.Equal([]int{entityID1.Index()}, m.freeEntities)
+}
+
+func TestArchetypeEntityComponentManager_DeleteEntity(t *testing.T) {
+	a := assert.New(t)
+	m := newArchetypeEntityComponentManager()
+
+	entityID1 := m.NewEntity("entity")
+	entityID2 := m.NewEntity("entity")
+
+	// Deleting an entity without components shouldn't move it within the root archetype
+	m.DeleteEntity(entityID1)
+	a.Equal([]EntityID{entityID1, entityID2}, m.root.entities)
+	a.Equal(0, m.entities[entityID1].row)
+	a.Equal(1, m.entities[entityID2].row)
+
+	_, err := newComponent1(m, entityID2)
+	a.NoError(err)
+	a.Equal([]EntityID{entityID1}, m.root.entities)
+
+	m.DeleteEmptyEntities()
+	a.Len(m.root.entities, 0)
+	a.True(m.entities[entityID1].deleted)
+	a.Equal(component1Value, m.GetEntity(entityID2).Get(componentType1).Data)
+}
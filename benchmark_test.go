@@ -1,7 +1,9 @@
 package ecs
 
 import (
+	"fmt"
 	"reflect"
+	"runtime"
 	"testing"
 )
 
@@ -34,22 +36,27 @@ type velocityComponent vec3
 var velocityComponentType = ComponentTypeID(reflect.TypeOf((*velocityComponent)(nil)).Elem())
 
 func BenchmarkNewComponent(b *testing.B) {
-	ec := NewEntityComponentManager()
-
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		for j := 0; j < 10000; j++ {
-			entity := ec.NewEntity("entity")
-			_, _ = ec.NewComponent(entity, transformComponentType, transformComponent{})
-			_, _ = ec.NewComponent(entity, positionComponentType, positionComponent{})
-			_, _ = ec.NewComponent(entity, rotationComponentType, rotationComponent{})
-			_, _ = ec.NewComponent(entity, velocityComponentType, velocityComponent{})
-		}
+	for _, impl := range entityComponentManagers {
+		b.Run(impl.name, func(b *testing.B) {
+			ec := impl.new()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < 10000; j++ {
+					entity := ec.NewEntity("entity")
+					_, _ = ec.NewComponent(entity, transformComponentType, transformComponent{})
+					_, _ = ec.NewComponent(entity, positionComponentType, positionComponent{})
+					_, _ = ec.NewComponent(entity, rotationComponentType, rotationComponent{})
+					_, _ = ec.NewComponent(entity, velocityComponentType, velocityComponent{})
+				}
+			}
+		})
 	}
 }
 
-func BenchmarkNewSystem(b *testing.B) {
-	ecs := New()
+// Creates an engine using the given EntityComponentManager with 10000 entities
+func newBenchmarkEngine(ec EntityComponentManager) *ECS {
+	ecs := New(WithEntityComponentManager(ec))
 	for i := 0; i < 10000; i++ {
 		entity := ecs.NewEntity("entity")
 		_, _ = ecs.NewComponent(entity, transformComponentType, transformComponent{})
@@ -57,40 +64,137 @@ func BenchmarkNewSystem(b *testing.B) {
 		_, _ = ecs.NewComponent(entity, rotationComponentType, rotationComponent{})
 		_, _ = ecs.NewComponent(entity, velocityComponentType, velocityComponent{})
 	}
+	return ecs
+}
+
+func BenchmarkGetEntities(b *testing.B) {
+	for _, impl := range entityComponentManagers {
+		b.Run(impl.name, func(b *testing.B) {
+			ecs := newBenchmarkEngine(impl.new())
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		ecs.NewSystem(func(*ECS, Event, Entity) {}, updateEventType, []ComponentTypeID{
-			positionComponentType, velocityComponentType})
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ecs.GetEntities([]ComponentTypeID{positionComponentType, velocityComponentType})
+			}
+		})
 	}
 }
 
-func BenchmarkRun(b *testing.B) {
-	ecs := New()
-	for i := 0; i < 10000; i++ {
-		entity := ecs.NewEntity("entity")
-		_, _ = ecs.NewComponent(entity, transformComponentType, transformComponent{})
-		_, _ = ecs.NewComponent(entity, positionComponentType, positionComponent{})
-		_, _ = ecs.NewComponent(entity, rotationComponentType, rotationComponent{})
-		_, _ = ecs.NewComponent(entity, velocityComponentType, velocityComponent{})
+func BenchmarkNewSystem(b *testing.B) {
+	for _, impl := range entityComponentManagers {
+		b.Run(impl.name, func(b *testing.B) {
+			ecs := newBenchmarkEngine(impl.new())
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ecs.NewSystem(func(*ECS, Event, Entity) {}, updateEventType, []ComponentTypeID{
+					positionComponentType, velocityComponentType})
+			}
+		})
 	}
+}
 
-	ecs.NewSystem(func(ecs *ECS, _ Event, e Entity) {
-		pos := e.Get(positionComponentType)
-		posData := pos.Data.(positionComponent)
-		vel := e.Get(velocityComponentType).Data.(velocityComponent)
+func BenchmarkRun(b *testing.B) {
+	for _, impl := range entityComponentManagers {
+		b.Run(impl.name, func(b *testing.B) {
+			ecs := newBenchmarkEngine(impl.new())
+
+			ecs.NewSystem(func(ecs *ECS, _ Event, e Entity) {
+				pos := e.Get(positionComponentType)
+				posData := pos.Data.(positionComponent)
+				vel := e.Get(velocityComponentType).Data.(velocityComponent)
 
-		posData.X += vel.X
-		posData.Y += vel.Y
-		posData.Z += vel.Z
+				posData.X += vel.X
+				posData.Y += vel.Y
+				posData.Z += vel.Z
 
-		ecs.UpdateComponent(pos.ID(), posData)
+				ecs.UpdateComponent(pos.ID(), posData)
 
-	}, updateEventType, []ComponentTypeID{positionComponentType, velocityComponentType})
+			}, updateEventType, []ComponentTypeID{positionComponentType, velocityComponentType})
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		ecs.NewEvent(updateEventType, updateEvent{})
-		ecs.Run()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ecs.NewEvent(updateEventType, updateEvent{})
+				ecs.Run()
+			}
+		})
+	}
+}
+
+func BenchmarkRunMutate(b *testing.B) {
+	for _, impl := range entityComponentManagers {
+		b.Run(impl.name, func(b *testing.B) {
+			ecs := newBenchmarkEngine(impl.new())
+
+			ecs.NewSystem(func(ecs *ECS, _ Event, e Entity) {
+				vel := Get[velocityComponent](e)
+				Mutate(e, func(pos *positionComponent) {
+					pos.X += vel.X
+					pos.Y += vel.Y
+					pos.Z += vel.Z
+				})
+			}, updateEventType, []ComponentTypeID{positionComponentType, velocityComponentType})
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ecs.NewEvent(updateEventType, updateEvent{})
+				ecs.Run()
+			}
+		})
+	}
+}
+
+// Multiplies two matrices, which is slow enough for a system to be worth splitting into chunks
+func (m mat4x4) mul(o mat4x4) mat4x4 {
+	result := mat4x4{}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				result.Mat[i][j] += m.Mat[i][k] * o.Mat[k][j]
+			}
+		}
+	}
+	return result
+}
+
+func BenchmarkRunChunked(b *testing.B) {
+	procs := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(procs)
+
+	for _, impl := range entityComponentManagers {
+		for _, chunkSize := range []int{0, 64, 256, 1024} {
+			for _, n := range []int{1, 2, 4, 8} {
+				if n > procs {
+					break
+				}
+				name := fmt.Sprintf("%s/chunk=%d/procs=%d", impl.name, chunkSize, n)
+				b.Run(name, func(b *testing.B) {
+					ecs := newBenchmarkEngine(impl.new())
+
+					id := ecs.NewSystem(func(ecs *ECS, _ Event, e Entity) {
+						transform := mat4x4(Get[transformComponent](e))
+						for i := 0; i < 8; i++ {
+							transform = transform.mul(transform)
+						}
+						Mutate(e, func(pos *positionComponent) {
+							pos.X += transform.Mat[3][0]
+							pos.Y += transform.Mat[3][1]
+							pos.Z += transform.Mat[3][2]
+						})
+					}, updateEventType, []ComponentTypeID{
+						transformComponentType, positionComponentType})
+					ecs.SetSystemChunkSize(id, chunkSize)
+
+					runtime.GOMAXPROCS(n)
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						ecs.NewEvent(updateEventType, updateEvent{})
+						ecs.Run()
+					}
+					b.StopTimer()
+					runtime.GOMAXPROCS(procs)
+				})
+			}
+		}
 	}
 }
@@ -0,0 +1,156 @@
+package ecs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// command is a deferred change to an EntityComponentManager
+type command func(EntityComponentManager) error
+
+// Commands is a buffer of structural changes (creating and deleting entities and components), which
+// are applied later instead of straight away. Systems should use the engine's Commands rather than
+// changing the entities they're iterating over. Commands are safe to record from multiple
+// goroutines
+type Commands struct {
+	lock     sync.Mutex
+	m        EntityComponentManager
+	commands []command
+}
+
+// NewCommands creates and returns an empty command buffer for the given manager
+func NewCommands(m EntityComponentManager) *Commands {
+	return &Commands{
+		m:        m,
+		commands: make([]command, 0),
+	}
+}
+
+func (c *Commands) push(cmd command) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.commands = append(c.commands, cmd)
+}
+
+// Spawn creates an empty entity with the given name straight away, and records giving it the given
+// components. The returned ID can be used by later commands. As the entity is empty until the
+// commands are applied, DeleteEmptyEntities will delete it if it is called first
+func (c *Commands) Spawn(name string, components map[ComponentTypeID]interface{}) EntityID {
+	eID := c.m.NewEntity(name)
+	// Create the components in order, so they are created the same way every time
+	types := make([]ComponentTypeID, 0, len(components))
+	for cType := range components {
+		types = append(types, cType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].String() != types[j].String() {
+			return types[i].String() < types[j].String()
+		}
+		return types[i].PkgPath() < types[j].PkgPath()
+	})
+	c.push(func(m EntityComponentManager) error {
+		errs := make([]error, 0)
+		for _, cType := range types {
+			_, err := m.NewComponent(eID, cType, components[cType])
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	})
+	return eID
+}
+
+// Add records creating a component of the given type in the given entity. If the entity isn't
+// alive when the commands are applied, an error is returned
+func (c *Commands) Add(eID EntityID, cType ComponentTypeID, data interface{}) {
+	c.push(func(m EntityComponentManager) error {
+		_, err := m.NewComponent(eID, cType, data)
+		return err
+	})
+}
+
+// Remove records deleting the given component. If the component doesn't exist when the commands are
+// applied this is a no-op
+func (c *Commands) Remove(id ComponentID) {
+	c.push(func(m EntityComponentManager) error {
+		m.DeleteComponent(id)
+		return nil
+	})
+}
+
+// Despawn records deleting the given entity. If the entity isn't alive when the commands are
+// applied this is a no-op
+func (c *Commands) Despawn(eID EntityID) {
+	c.push(func(m EntityComponentManager) error {
+		m.DeleteEntity(eID)
+		return nil
+	})
+}
+
+// Len returns the number of commands waiting to be applied
+func (c *Commands) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.commands)
+}
+
+// Apply applies the commands in the order they were recorded, and then clears the buffer. A command
+// failing doesn't stop the others from being applied, and all the errors are returned joined
+// together
+func (c *Commands) Apply() error {
+	// Take the commands so new ones can be recorded while these are applied
+	c.lock.Lock()
+	commands := c.commands
+	c.commands = make([]command, 0)
+	c.lock.Unlock()
+
+	errs := make([]error, 0)
+	for _, cmd := range commands {
+		errs = append(errs, cmd(c.m))
+	}
+	return errors.Join(errs...)
+}
+
+// deferredEntityComponentManager is an EntityComponentManager that records structural changes
+// (creating and deleting components, and deleting entities) in a command buffer instead of making
+// them, as they change which entities the systems act on while the systems may be running
+type deferredEntityComponentManager struct {
+	EntityComponentManager
+	commands *Commands
+}
+
+// NewComponent records creating the component. The component doesn't exist until the commands are
+// applied, so the returned ID has the ID -1 and doesn't refer to it
+func (m deferredEntityComponentManager) NewComponent(
+	eID EntityID, cType ComponentTypeID, data interface{}) (ComponentID, error) {
+	if !m.IsAlive(eID) {
+		return ComponentID{}, fmt.Errorf("entity %d is not alive", eID)
+	}
+	m.commands.Add(eID, cType, data)
+	return ComponentID{
+		ID:              -1,
+		ComponentTypeID: cType,
+	}, nil
+}
+
+func (m deferredEntityComponentManager) NewComponentReflect(
+	eID EntityID, data interface{}) (ComponentID, error) {
+	return m.NewComponent(eID, reflect.TypeOf(data), data)
+}
+
+func (m deferredEntityComponentManager) DeleteEntity(id EntityID) {
+	m.commands.Despawn(id)
+}
+
+func (m deferredEntityComponentManager) DeleteComponent(id ComponentID) {
+	m.commands.Remove(id)
+}
+
+func (m deferredEntityComponentManager) DeleteEmptyEntities() {
+	m.commands.push(func(m EntityComponentManager) error {
+		m.DeleteEmptyEntities()
+		return nil
+	})
+}
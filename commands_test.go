@@ -0,0 +1,70 @@
+package ecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCommands_Apply(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		c := NewCommands(m)
+
+		entityID := m.NewEntity("entity")
+		componentID, err := newComponent1(m, entityID)
+		a.NoError(err)
+		despawnedID := m.NewEntity("despawned")
+		_, err = newComponent1(m, despawnedID)
+		a.NoError(err)
+
+		spawnedID := c.Spawn("spawned", map[ComponentTypeID]interface{}{
+			componentType1: component1Value,
+			componentType2: component2Value,
+		})
+		// The spawned entity is reserved straight away, so later commands can use it
+		a.True(m.IsAlive(spawnedID))
+		c.Add(spawnedID, componentType3, component3Value)
+		c.Add(entityID, componentType2, component2Value)
+		c.Remove(componentID)
+		c.Despawn(despawnedID)
+		a.Equal(5, c.Len())
+
+		// Nothing should change until the commands are applied
+		a.Len(m.QueryEntityIDs(NewQuery().With(componentType1)), 2)
+		a.False(m.GetEntity(entityID).Has(componentType2))
+
+		a.NoError(c.Apply())
+		a.Equal(0, c.Len())
+
+		spawned := m.QueryEntities(NewQuery().With(componentType1, componentType2, componentType3))
+		a.Len(spawned, 1)
+		a.Equal(spawnedID, spawned[0].ID())
+		a.Equal("spawned", spawned[0].Name())
+
+		entity := m.GetEntity(entityID)
+		a.False(entity.Has(componentType1))
+		a.True(entity.Has(componentType2))
+
+		a.False(m.GetEntity(despawnedID).Has(componentType1))
+	})
+}
+
+func TestCommands_ApplyErrors(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		c := NewCommands(m)
+
+		entityID := m.NewEntity("entity")
+		_, err := newComponent1(m, entityID)
+		a.NoError(err)
+
+		// A duplicate component and a dead entity should both fail, without stopping the other
+		// commands
+		c.Add(entityID, componentType1, component1Value)
+		c.Add(EntityID(100), componentType1, component1Value)
+		c.Add(entityID, componentType2, component2Value)
+
+		err = c.Apply()
+		a.Error(err)
+		a.Len(err.(interface{ Unwrap() []error }).Unwrap(), 2)
+		a.True(m.GetEntity(entityID).Has(componentType2))
+	})
+}
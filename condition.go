@@ -0,0 +1,66 @@
+package ecs
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// RunCondition is checked before a system runs for an event. If it returns false the system doesn't
+// run for the event, without iterating over its entities. Panics in a condition are recovered like
+// panics in the system
+type RunCondition func(*ECS, Event) bool
+
+// WorldEquals creates a condition that the world value with the given key equals the given value,
+// which has to be comparable
+func WorldEquals(key string, value interface{}) RunCondition {
+	return func(ecs *ECS, _ Event) bool {
+		v, ok := ecs.World[key]
+		return ok && v == value
+	}
+}
+
+// WorldHas creates a condition that the world has a value with the given key
+func WorldHas(key string) RunCondition {
+	return func(ecs *ECS, _ Event) bool {
+		_, ok := ecs.World[key]
+		return ok
+	}
+}
+
+// AnyMatches creates a condition that at least one entity matches the given cached query, so the
+// condition doesn't have to check every entity. Unless the query has change filters, this is only
+// the number of entities in the cache
+func AnyMatches(q *CachedQuery) RunCondition {
+	return func(*ECS, Event) bool {
+		if !q.query.hasChangeFilters() {
+			return q.Len() > 0
+		}
+		// Stop at the first entity that matches the change filters
+		found := false
+		_, _ = q.ForEntities(func(Entity) (bool, error) {
+			found = true
+			return false, nil
+		})
+		return found
+	}
+}
+
+// EveryN creates a condition that is true every nth time it is checked, starting with the first.
+// As the count is kept by the condition, it shouldn't be shared between systems. Panics if n is
+// less than 1
+func EveryN(n int) RunCondition {
+	if n < 1 {
+		panic(fmt.Errorf("n must be at least 1, got %d", n))
+	}
+	var count atomic.Uint64
+	return func(*ECS, Event) bool {
+		return (count.Add(1)-1)%uint64(n) == 0
+	}
+}
+
+// Not creates a condition that is true when the given condition is false
+func Not(condition RunCondition) RunCondition {
+	return func(ecs *ECS, event Event) bool {
+		return !condition(ecs, event)
+	}
+}
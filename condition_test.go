@@ -0,0 +1,95 @@
+package ecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRunConditions(t *testing.T) {
+	a := assert.New(t)
+	ecs := New()
+	event := Event{EventTypeID: EventType1}
+
+	paused := WorldEquals("paused", true)
+	a.False(paused(ecs, event))
+	ecs.World["paused"] = true
+	a.True(paused(ecs, event))
+	a.False(Not(paused)(ecs, event))
+
+	a.True(WorldHas("paused")(ecs, event))
+	a.False(WorldHas("resource")(ecs, event))
+
+	cache := ecs.NewCachedQuery(NewQuery().With(componentType1))
+	defer cache.Close()
+	anyMatches := AnyMatches(cache)
+	a.False(anyMatches(ecs, event))
+	tick := ecs.ChangeTick()
+	ecs.AdvanceChangeTick()
+	componentID, err := newComponent1(ecs, ecs.NewEntity("entity"))
+	a.NoError(err)
+	a.True(anyMatches(ecs, event))
+
+	// Change filters should be checked too
+	changed := ecs.NewCachedQuery(NewQuery().Changed(componentType1).Since(tick))
+	defer changed.Close()
+	a.True(AnyMatches(changed)(ecs, event))
+	changed = ecs.NewCachedQuery(NewQuery().Changed(componentType1).Since(ecs.ChangeTick()))
+	defer changed.Close()
+	a.False(AnyMatches(changed)(ecs, event))
+	ecs.AdvanceChangeTick()
+	ecs.UpdateComponent(componentID, 2)
+	a.True(AnyMatches(changed)(ecs, event))
+
+	everyN := EveryN(3)
+	results := make([]bool, 0)
+	for i := 0; i < 6; i++ {
+		results = append(results, everyN(ecs, event))
+	}
+	a.Equal([]bool{true, false, false, true, false, false}, results)
+	a.Panics(func() {
+		EveryN(0)
+	})
+	a.Panics(func() {
+		EveryN(-1)
+	})
+}
+
+func TestSystemManager_AddSystemCondition(t *testing.T) {
+	a := assert.New(t)
+	m, ids, ran := newScheduleTestSystems(2)
+	m.ecs.World = make(map[string]interface{})
+
+	m.AddSystemCondition(ids[0], Not(WorldEquals("paused", true)))
+	m.AddSystemCondition(ids[1], EveryN(2))
+	a.Len(m.GetSystem(ids[0]).Conditions(), 1)
+
+	for _, test := range []struct {
+		paused bool
+		ran    []SystemID
+	}{
+		{false, ids},
+		{false, []SystemID{ids[0]}},
+		{true, []SystemID{ids[1]}},
+		{true, []SystemID{}},
+	} {
+		m.ecs.World["paused"] = test.paused
+		*ran = (*ran)[:0]
+		a.NoError(m.RunSystems(Event{EventTypeID: EventType1}))
+		a.Equal(test.ran, *ran)
+	}
+
+	// A panicking condition should be returned as an error, even when running in parallel
+	m.AddSystemCondition(ids[0], func(*ECS, Event) bool {
+		panic("condition")
+	})
+	m.ecs.World["paused"] = false
+	*ran = (*ran)[:0]
+	err := m.RunSystemsParallel(Event{EventTypeID: EventType1})
+	var systemErr *SystemError
+	a.ErrorAs(err, &systemErr)
+	a.Equal(ids[0], systemErr.SystemID)
+	var panicErr *PanicError
+	a.ErrorAs(err, &panicErr)
+	a.Equal("condition", panicErr.Value)
+	a.Equal([]SystemID{ids[1]}, *ran)
+}
@@ -2,8 +2,10 @@ package ecs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 )
@@ -13,35 +15,227 @@ type ECS struct {
 	EventManager
 	SystemManager
 	World map[string]interface{}
+
+	commands    *Commands
+	commandSync CommandSync
+
+	eventPolicy    EventPolicy
+	maxEventPasses int
+
+	errorPolicy ErrorPolicy
+	onPanic     PanicHandler
+
+	timerClock Clock
+	recorder   *Recorder
+}
+
+// CommandSync is when Run applies the commands recorded by systems
+type CommandSync int
+
+const (
+	// CommandSyncEvent applies the commands after the systems have run for each event, so the
+	// systems for the next event see the changes. This is the default
+	CommandSyncEvent CommandSync = iota
+	// CommandSyncRun applies the commands once all the events have been handled
+	CommandSyncRun
+)
+
+// Option is an option for creating an ECS engine with New
+type Option func(*ECS)
+
+// WithEntityComponentManager makes the engine use the given entity component manager, for example
+// one created by NewArchetypeEntityComponentManager
+func WithEntityComponentManager(m EntityComponentManager) Option {
+	return func(ecs *ECS) {
+		ecs.EntityComponentManager = m
+	}
+}
+
+// EventPolicy is what Run does with the events created while it is handling events
+type EventPolicy int
+
+const (
+	// EventsNextFrame leaves the events in the queue for the next Run. This is the default
+	EventsNextFrame EventPolicy = iota
+	// EventsDrain handles the events in the same Run, until no more are created or the maximum
+	// number of passes is reached (see WithMaxEventPasses)
+	EventsDrain
+)
+
+// DefaultMaxEventPasses is the default maximum number of passes over the event queue Run makes with
+// the EventsDrain policy
+const DefaultMaxEventPasses = 16
+
+// WithEventPolicy sets what Run does with the events created while it is handling events
+func WithEventPolicy(policy EventPolicy) Option {
+	return func(ecs *ECS) {
+		ecs.eventPolicy = policy
+	}
+}
+
+// WithMaxEventPasses sets the maximum number of passes over the event queue Run makes with the
+// EventsDrain policy. Each pass handles the events created during the previous one
+func WithMaxEventPasses(n int) Option {
+	return func(ecs *ECS) {
+		ecs.maxEventPasses = n
+	}
+}
+
+// WithErrorPolicy sets what happens when a system returns an error. See
+// SystemManager.SetErrorPolicy
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(ecs *ECS) {
+		ecs.errorPolicy = policy
+	}
+}
+
+// WithPanicHandler sets the function called when a system panics. See
+// SystemManager.SetPanicHandler
+func WithPanicHandler(handler PanicHandler) Option {
+	return func(ecs *ECS) {
+		ecs.onPanic = handler
+	}
+}
+
+// WithTimerClock makes the engine's timers use the given clock instead of the system time. See
+// EventManager.NewEventAfter
+func WithTimerClock(clock Clock) Option {
+	return func(ecs *ECS) {
+		ecs.timerClock = clock
+	}
+}
+
+// WithCommandSync sets when Run applies the commands recorded by systems
+func WithCommandSync(sync CommandSync) Option {
+	return func(ecs *ECS) {
+		ecs.commandSync = sync
+	}
 }
 
 // New creates and returns an ECS engine
-func New() (ecs *ECS) {
-	ecs = &ECS{}
-	ecs.EntityComponentManager = NewEntityComponentManager()
-	ecs.EventManager = NewEventManager()
+func New(options ...Option) (ecs *ECS) {
+	ecs = &ECS{
+		maxEventPasses: DefaultMaxEventPasses,
+	}
+	for _, option := range options {
+		option(ecs)
+	}
+	if ecs.EntityComponentManager == nil {
+		ecs.EntityComponentManager = NewEntityComponentManager()
+	}
+	if ecs.timerClock != nil {
+		ecs.EventManager = NewEventManagerWithClock(ecs.timerClock)
+	} else {
+		ecs.EventManager = NewEventManager()
+	}
+	if ecs.recorder != nil {
+		if ecs.timerClock != nil {
+			ecs.recorder.clock = ecs.timerClock
+		}
+		ecs.EventManager = recordingEventManager{
+			EventManager: ecs.EventManager,
+			recorder:     ecs.recorder,
+		}
+	}
+	ecs.commands = NewCommands(ecs.EntityComponentManager)
 	ecs.SystemManager = NewSystemManager(ecs)
+	ecs.SetErrorPolicy(ecs.errorPolicy)
+	ecs.SetPanicHandler(ecs.onPanic)
 	ecs.World = make(map[string]interface{})
 	return
 }
 
-// Run runs the ECS once. This will do nothing if the event manager is empty
-func (ecs *ECS) Run() {
-	_, _ = ecs.ForEvents(func(event Event) (bool, error) {
-		ecs.RunSystems(event)
-		return true, nil
-	})
-	ecs.ClearEvents()
+// Commands returns the engine's command buffer. Creating and deleting components and deleting
+// entities with the engine given to a system records the change here, as the systems may be running
+// at the same time, so it is applied at the sync point set by WithCommandSync
+func (ecs *ECS) Commands() *Commands {
+	return ecs.commands
 }
 
-// RunParallel runs the ECS once, using goroutines. This will do nothing if the event manager is
-// empty
-func (ecs *ECS) RunParallel() {
-	_, _ = ecs.ForEvents(func(event Event) (bool, error) {
-		ecs.RunSystemsParallel(event)
-		return true, nil
-	})
-	ecs.ClearEvents()
+// ApplyCommands applies the commands in the engine's command buffer. Run calls this itself
+func (ecs *ECS) ApplyCommands() error {
+	return ecs.commands.Apply()
+}
+
+// Returns the engine for systems to use, which is a copy that records structural changes in the
+// command buffer. If the engine is being recorded, the copy also doesn't record the events the
+// systems create
+func (ecs *ECS) systemECS() *ECS {
+	systemECS := *ecs
+	systemECS.EntityComponentManager = deferredEntityComponentManager{
+		EntityComponentManager: ecs.EntityComponentManager,
+		commands:               ecs.commands,
+	}
+	if m, ok := ecs.EventManager.(recordingEventManager); ok {
+		systemECS.EventManager = m.EventManager
+	}
+	return &systemECS
+}
+
+// Runs the ECS once, running the systems with RunSystemsParallel if parallel is true or RunSystems
+// otherwise
+func (ecs *ECS) run(parallel bool) error {
+	runSystems := ecs.RunSystems
+	if parallel {
+		runSystems = ecs.RunSystemsParallel
+	}
+	if ecs.recorder != nil {
+		ecs.recorder.startRun(parallel, ecs.eventPolicy, ecs.maxEventPasses)
+	}
+	ecs.AdvanceTimers()
+	errs := make([]error, 0)
+	events := ecs.TakeEvents()
+	for pass := 1; len(events) > 0; pass++ {
+		for i, event := range events {
+			err := runSystems(event)
+			if err != nil && ecs.ErrorPolicy() == ErrorStop {
+				// Leave the events that weren't handled for the next Run
+				ecs.RequeueEvents(events[i+1:])
+				return errors.Join(err, ecs.ApplyCommands())
+			}
+			errs = append(errs, err)
+			if ecs.commandSync == CommandSyncEvent {
+				errs = append(errs, ecs.ApplyCommands())
+			}
+		}
+
+		// Events created during the pass are left for the next Run
+		if ecs.eventPolicy != EventsDrain {
+			break
+		}
+		if pass >= ecs.maxEventPasses {
+			// Count the events that are left
+			n := 0
+			_, _ = ecs.ForEvents(func(Event) (bool, error) {
+				n++
+				return true, nil
+			})
+			if n > 0 {
+				errs = append(errs, fmt.Errorf(
+					"%d events still queued after %d passes, leaving them for the next run",
+					n, pass))
+			}
+			break
+		}
+		events = ecs.TakeEvents()
+	}
+	errs = append(errs, ecs.ApplyCommands())
+	return errors.Join(errs...)
+}
+
+// Run runs the ECS once. The timers are advanced first, creating the events that are due, and then
+// this will do nothing if the event manager is empty. Events created while the events are handled
+// are dealt with according to the policy set by WithEventPolicy. The commands recorded by the
+// systems are applied at the sync point set by WithCommandSync. Any errors from the systems (see
+// WithErrorPolicy), applying the commands or draining the events are returned
+func (ecs *ECS) Run() error {
+	return ecs.run(false)
+}
+
+// RunParallel runs the ECS once, using goroutines. The timers, events and commands are handled the
+// same as Run
+func (ecs *ECS) RunParallel() error {
+	return ecs.run(true)
 }
 
 // Dump returns a dump of the state of the engine into a string
@@ -106,6 +300,10 @@ func (ecs *ECS) DumpJSON() (string, error) {
 				Data: component.Data,
 			})
 		}
+		// Sort the components so dumps of the same state are the same
+		sort.Slice(dumpEntity.Components, func(i, j int) bool {
+			return dumpEntity.Components[i].Type < dumpEntity.Components[j].Type
+		})
 		dump.Entities = append(dump.Entities, dumpEntity)
 		return true, nil
 	})
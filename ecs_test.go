@@ -63,3 +63,141 @@ func TestECS_Counter(t *testing.T) {
 
 	a.Equal(5, ecs.GetComponent(componentID))
 }
+
+func TestNew_WithEntityComponentManager(t *testing.T) {
+	a := assert.New(t)
+	m := NewArchetypeEntityComponentManager()
+	ecs := New(WithEntityComponentManager(m))
+	a.Equal(m, ecs.EntityComponentManager)
+
+	entityID := ecs.NewEntity("entity")
+	_, err := newComponent1(ecs, entityID)
+	a.NoError(err)
+
+	systemFuncCalled := false
+	ecs.NewSystem(func(_ *ECS, _ Event, entity Entity) {
+		a.Equal(entityID, entity.ID())
+		systemFuncCalled = true
+	}, EventType1, []ComponentTypeID{componentType1})
+
+	newEvent1(ecs)
+	ecs.Run()
+
+	a.True(systemFuncCalled)
+}
+
+func TestECS_Commands(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		sync     CommandSync
+		expected int
+	}{
+		// The second event's system should see the entities spawned by the first
+		{"event", CommandSyncEvent, 3},
+		{"run", CommandSyncRun, 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a := assert.New(t)
+			ecs := New(WithCommandSync(test.sync))
+
+			_, err := newComponent1(ecs, ecs.NewEntity("entity"))
+			a.NoError(err)
+
+			calls := 0
+			ecs.NewSystem(func(ecs *ECS, _ Event, entity Entity) {
+				calls++
+				ecs.Commands().Spawn("spawned", map[ComponentTypeID]interface{}{
+					componentType1: component1Value,
+				})
+			}, EventType1, []ComponentTypeID{componentType1})
+
+			newEvent1(ecs)
+			newEvent1(ecs)
+			a.NoError(ecs.Run())
+			a.Equal(test.expected, calls)
+			a.Equal(0, ecs.Commands().Len())
+			a.Len(ecs.GetEntityIDs([]ComponentTypeID{componentType1}), 1+test.expected)
+
+			// Errors from applying the commands should be returned
+			ecs.Commands().Add(EntityID(100), componentType1, component1Value)
+			a.Error(ecs.Run())
+		})
+	}
+}
+
+func TestECS_RunParallelStructuralChanges(t *testing.T) {
+	a := assert.New(t)
+	ecs := New()
+
+	for i := 0; i < 100; i++ {
+		entityID := ecs.NewEntity("entity")
+		_, err := newComponent1(ecs, entityID)
+		a.NoError(err)
+		_, err = newComponent3(ecs, entityID)
+		a.NoError(err)
+	}
+
+	// The first system creates components while the second iterates over the same entities, which
+	// is only safe because the components aren't created until the systems have finished
+	adder := ecs.NewSystem(func(ecs *ECS, _ Event, entity Entity) {
+		_, err := ecs.NewComponent(entity.ID(), componentType2, component2Value)
+		a.NoError(err)
+		a.False(ecs.GetEntity(entity.ID()).Has(componentType2))
+	}, EventType1, []ComponentTypeID{componentType1})
+	ecs.SetSystemAccess(adder, []ComponentTypeID{componentType1}, nil)
+	reader := ecs.NewSystem(func(_ *ECS, _ Event, entity Entity) {
+		entity.Has(componentType3)
+	}, EventType1, []ComponentTypeID{componentType3})
+	ecs.SetSystemAccess(reader, []ComponentTypeID{componentType3}, nil)
+
+	newEvent1(ecs)
+	a.NoError(ecs.RunParallel())
+	a.Len(ecs.GetEntityIDs([]ComponentTypeID{componentType2}), 100)
+}
+
+func TestECS_EventPolicy(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		options  []Option
+		expected []int
+		err      bool
+	}{
+		{"next frame", nil, []int{1}, false},
+		{"drain", []Option{WithEventPolicy(EventsDrain)}, []int{1, 2, 3}, false},
+		{"max passes", []Option{WithEventPolicy(EventsDrain), WithMaxEventPasses(2)},
+			[]int{1, 2}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a := assert.New(t)
+			ecs := New(test.options...)
+
+			_, err := newComponent1(ecs, ecs.NewEntity("entity"))
+			a.NoError(err)
+
+			// Each event creates the next one, up to 3
+			handled := make([]int, 0)
+			ecs.NewSystem(func(ecs *ECS, event Event, _ Entity) {
+				n := event.Data.(int)
+				handled = append(handled, n)
+				if n < 3 {
+					ecs.NewEvent(EventType1, n+1)
+				}
+			}, EventType1, []ComponentTypeID{componentType1})
+
+			ecs.NewEvent(EventType1, 1)
+			err = ecs.Run()
+			if test.err {
+				a.Error(err)
+			} else {
+				a.NoError(err)
+			}
+			a.Equal(test.expected, handled)
+
+			// Any events left over should be handled by the next run
+			for len(handled) < 3 {
+				a.NoError(ecs.Run())
+			}
+			a.Equal([]int{1, 2, 3}, handled)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Package ecstest has helpers for testing code that uses the ecs package
+package ecstest
+
+import (
+	"encoding/json"
+	"github.com/bhollier/ecs"
+	"io"
+	"testing"
+)
+
+// AssertReplay replays the recording on the engine using ecs.Replay, and reports an error if
+// replaying fails or the engine doesn't end in the state the recording was finished with. The
+// engine has to be set up the same way as the recorded engine was, with clock as its timer clock
+// if the recorded engine used timers. Returns whether the replay matched the recording
+func AssertReplay(t testing.TB, engine *ecs.ECS, recording io.Reader, clock *ecs.ManualClock,
+	eventTypes ...ecs.EventTypeID) bool {
+	t.Helper()
+
+	expected, err := ecs.Replay(engine, recording, clock, eventTypes...)
+	if err != nil {
+		t.Errorf("replay failed: %v", err)
+		return false
+	}
+	if expected == "" {
+		t.Errorf("recording wasn't finished with the engine's state")
+		return false
+	}
+	actual, err := engine.DumpJSON()
+	if err != nil {
+		t.Errorf("failed to dump the engine: %v", err)
+		return false
+	}
+
+	// The dumps are from different times, so ignore their timestamps
+	expected, err = withoutTimestamp(expected)
+	if err != nil {
+		t.Errorf("failed to parse the recorded state: %v", err)
+		return false
+	}
+	actual, err = withoutTimestamp(actual)
+	if err != nil {
+		t.Errorf("failed to parse the engine's state: %v", err)
+		return false
+	}
+	if expected != actual {
+		t.Errorf("engine state doesn't match the recording:\nexpected: %s\nactual:   %s",
+			expected, actual)
+		return false
+	}
+	return true
+}
+
+// Returns the JSON dump without its timestamp
+func withoutTimestamp(dump string) (string, error) {
+	var v map[string]interface{}
+	err := json.Unmarshal([]byte(dump), &v)
+	if err != nil {
+		return "", err
+	}
+	delete(v, "timestamp")
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
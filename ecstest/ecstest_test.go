@@ -0,0 +1,64 @@
+package ecstest
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bhollier/ecs"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+var counterType = reflect.TypeOf((*int)(nil)).Elem()
+
+// recordingT records the errors reported to it
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+// Creates an engine with a system that adds the events to a counter
+func newEngine(options ...ecs.Option) *ecs.ECS {
+	engine := ecs.New(options...)
+	_, _ = engine.NewComponent(engine.NewEntity("counter"), counterType, 0)
+	engine.NewSystem(func(_ *ecs.ECS, event ecs.Event, entity ecs.Entity) {
+		ecs.Mutate(entity, func(count *int) {
+			*count += event.Data.(int)
+		})
+	}, counterType, []ecs.ComponentTypeID{counterType})
+	return engine
+}
+
+func TestAssertReplay(t *testing.T) {
+	a := assert.New(t)
+	buf := bytes.Buffer{}
+	recorder := ecs.NewRecorder(&buf)
+	engine := newEngine(ecs.WithRecorder(recorder))
+	for i := 1; i <= 3; i++ {
+		engine.NewEvent(counterType, i)
+		a.NoError(engine.Run())
+	}
+	a.NoError(recorder.Finish(engine))
+	recording := buf.Bytes()
+
+	a.True(AssertReplay(t, newEngine(), bytes.NewReader(recording), nil, counterType))
+
+	// An engine that isn't set up the same way should fail
+	engine = newEngine()
+	engine.NewEvent(counterType, 1)
+	a.NoError(engine.Run())
+	failing := &recordingT{TB: t}
+	a.False(AssertReplay(failing, engine, bytes.NewReader(recording), nil, counterType))
+	a.Len(failing.errors, 1)
+
+	// As should a recording that wasn't finished
+	failing = &recordingT{TB: t}
+	a.False(AssertReplay(failing, newEngine(), &bytes.Buffer{}, nil, counterType))
+	a.Len(failing.errors, 1)
+}
@@ -3,16 +3,20 @@ package ecs
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 // ComponentTypeID is an identifier for a component type
 type ComponentTypeID reflect.Type
 
-// ComponentID is an identifier for a component
+// ComponentID is an identifier for a component. The generation is incremented every time an ID is
+// reused, so an ID kept after its component was deleted can't refer to a different component
 type ComponentID struct {
 	ID int
 	ComponentTypeID
+	Generation uint32
 }
 
 // Component is a component's data and its ID
@@ -31,27 +35,147 @@ func (c Component) Update(ecs *ECS) {
 	ecs.UpdateComponent(c.id, c.Data)
 }
 
-// EntityID is an identifier for an entity
-type EntityID int
+// ComponentTicks are the change ticks of a component. See EntityComponentManager.ChangeTick
+type ComponentTicks struct {
+	// Added is the tick the component was created at
+	Added uint64
+	// Changed is the tick the component was last updated at, or created at if it hasn't been
+	Changed uint64
+}
+
+// EntityID is an identifier for an entity. The lower 32 bits are the entity's index and the upper
+// 32 bits are its generation. The generation is incremented every time an index is reused, so an
+// ID kept after its entity was deleted can't refer to a different entity
+type EntityID uint64
+
+func newEntityID(index int, generation uint32) EntityID {
+	return EntityID(uint64(generation)<<32 | uint64(uint32(index)))
+}
+
+// Index returns the index of the entity
+func (id EntityID) Index() int {
+	return int(uint32(id))
+}
+
+// Generation returns the generation of the entity
+func (id EntityID) Generation() uint32 {
+	return uint32(id >> 32)
+}
+
+// Returns the IDs in the set in order, so iterating over them is the same every time
+func sortedEntityIDs(set map[EntityID]struct{}) []EntityID {
+	ids := make([]EntityID, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
 
 type componentPtr struct {
 	*sync.RWMutex
-	id  int
-	ptr *interface{}
+	id         int
+	generation uint32
+	ptr        *interface{}
+	ticks      *ComponentTicks
+	// The manager's change tick, for when the component is updated
+	tick *atomic.Uint64
+}
+
+// componentPtrs is a map of componentPtr (indexed by their component type)
+type componentPtrs map[ComponentTypeID]componentPtr
+
+func (p componentPtrs) has(t ComponentTypeID) bool {
+	_, ok := p[t]
+	return ok
+}
+
+func (p componentPtrs) get(t ComponentTypeID) (Component, bool) {
+	c, ok := p[t]
+	if !ok {
+		return Component{}, false
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return Component{
+		id: ComponentID{
+			ID:              c.id,
+			ComponentTypeID: t,
+			Generation:      c.generation,
+		},
+		Data: *c.ptr,
+	}, true
+}
+
+func (p componentPtrs) update(t ComponentTypeID, f func(data *interface{})) bool {
+	c, ok := p[t]
+	if !ok {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+	f(c.ptr)
+	c.ticks.Changed = c.tick.Load()
+	return true
+}
+
+func (p componentPtrs) ticks(t ComponentTypeID) (ComponentTicks, bool) {
+	c, ok := p[t]
+	if !ok {
+		return ComponentTicks{}, false
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return *c.ticks, true
+}
+
+func (p componentPtrs) all() map[ComponentTypeID]Component {
+	components := make(map[ComponentTypeID]Component, len(p))
+	for cType := range p {
+		components[cType], _ = p.get(cType)
+	}
+	return components
+}
+
+// entityComponentPtrs is the entityComponents of an entity stored by entityComponentManager
+type entityComponentPtrs struct {
+	componentPtrs
+	removedTicks map[ComponentTypeID]uint64
+}
+
+func (p entityComponentPtrs) removed(t ComponentTypeID) (uint64, bool) {
+	tick, ok := p.removedTicks[t]
+	return tick, ok
 }
 
 // An entity is just a map of componentPtr (indexed by their component type)
 type entity struct {
 	name       string
-	components map[ComponentTypeID]componentPtr
+	components componentPtrs
+	// The change tick each component type was last removed at
+	removed    map[ComponentTypeID]uint64
+	generation uint32
 	deleted    bool
 }
 
+// entityComponents is how an Entity accesses its components, which depends on the
+// EntityComponentManager that stores them
+type entityComponents interface {
+	has(ComponentTypeID) bool
+	get(ComponentTypeID) (Component, bool)
+	update(ComponentTypeID, func(data *interface{})) bool
+	all() map[ComponentTypeID]Component
+	ticks(ComponentTypeID) (ComponentTicks, bool)
+	removed(ComponentTypeID) (uint64, bool)
+}
+
 // Entity is a collection of components and its ID
 type Entity struct {
 	id         EntityID
 	name       string
-	components map[ComponentTypeID]componentPtr
+	components entityComponents
 }
 
 // ID returns the entity's ID
@@ -66,68 +190,69 @@ func (e Entity) Name() string {
 
 // Has returns whether the entity "has" a component with the given type
 func (e Entity) Has(t ComponentTypeID) bool {
-	_, ok := e.components[t]
-	return ok
+	if e.components == nil {
+		return false
+	}
+	return e.components.has(t)
 }
 
 // Get returns a copy of the component with the given type ID
 func (e Entity) Get(t ComponentTypeID) Component {
-	c := e.components[t]
-	c.RLock()
-	defer c.RUnlock()
-	return Component{
-		id: ComponentID{
-			ID:              c.id,
-			ComponentTypeID: t,
-		},
-		Data: *c.ptr,
-	}
+	c, _ := e.GetSafe(t)
+	return c
 }
 
 // GetSafe returns a combination of Get and Has. Equivalent to:
 //  val, ok := entity.Components[componentType]
 func (e Entity) GetSafe(t ComponentTypeID) (Component, bool) {
-	c, ok := e.components[t]
-	if !ok {
+	if e.components == nil {
 		return Component{}, false
 	}
-	c.RLock()
-	defer c.RUnlock()
-	return Component{
-		id: ComponentID{
-			ID:              c.id,
-			ComponentTypeID: t,
-		},
-		Data: *c.ptr,
-	}, true
+	return e.components.get(t)
 }
 
-// Components returns a map of all the components the entity has
-func (e Entity) Components() map[ComponentTypeID]Component {
-	components := make(map[ComponentTypeID]Component, len(e.components))
-	addComp := func(cType ComponentTypeID, c componentPtr) {
-		c.RLock()
-		defer c.RUnlock()
-		components[cType] = Component{
-			id: ComponentID{
-				ID:              c.id,
-				ComponentTypeID: cType,
-			},
-			Data: *c.ptr,
-		}
+// UpdateFunc calls f with a pointer to the data of the component with the given type ID, so it can
+// be modified in place. The lock on the component's storage is held until f returns, so f must not
+// use the ECS. Returns false if the entity doesn't have the component
+func (e Entity) UpdateFunc(t ComponentTypeID, f func(data *interface{})) bool {
+	if e.components == nil {
+		return false
 	}
+	return e.components.update(t, f)
+}
 
-	for cType, c := range e.components {
-		addComp(cType, c)
+// Ticks returns the change ticks of the component with the given type ID, and whether the entity
+// has the component
+func (e Entity) Ticks(t ComponentTypeID) (ComponentTicks, bool) {
+	if e.components == nil {
+		return ComponentTicks{}, false
 	}
+	return e.components.ticks(t)
+}
 
-	return components
+// RemovedTick returns the change tick a component with the given type ID was last removed from the
+// entity at, and whether one ever was
+func (e Entity) RemovedTick(t ComponentTypeID) (uint64, bool) {
+	if e.components == nil {
+		return 0, false
+	}
+	return e.components.removed(t)
+}
+
+// Components returns a map of all the components the entity has
+func (e Entity) Components() map[ComponentTypeID]Component {
+	if e.components == nil {
+		return map[ComponentTypeID]Component{}
+	}
+	return e.components.all()
 }
 
 type component struct {
-	entity  EntityID
-	data    interface{}
-	deleted bool
+	entity     EntityID
+	data       interface{}
+	ticks      ComponentTicks
+	generation uint32
+	deleted    bool
 }
 
 const componentBlockSize = 64
@@ -138,14 +263,19 @@ type componentBlock [componentBlockSize]component
 type componentTypeManager struct {
 	// the type manager mutex
 	sync.RWMutex
-	components []componentBlock
-	len        int
+	// The blocks are pointers so components don't move when a block is added
+	components []*componentBlock
+	// The number of components that have been used (including deleted ones)
+	len int
+	// The IDs of deleted components, which will be reused before len grows
+	free []int
 }
 
 func newComponentTypeManager() *componentTypeManager {
 	return &componentTypeManager{
-		components: make([]componentBlock, 1),
+		components: []*componentBlock{{}},
 		len:        0,
+		free:       make([]int, 0),
 	}
 }
 
@@ -153,49 +283,85 @@ func (m *componentTypeManager) get(id int) component {
 	return m.components[id/componentBlockSize][id%componentBlockSize]
 }
 
-func (m *componentTypeManager) getSafe(id int) (component, bool) {
-	if id >= m.len {
+func (m *componentTypeManager) getSafe(id ComponentID) (component, bool) {
+	if id.ID < 0 || id.ID >= m.len {
+		return component{}, false
+	}
+	c := m.get(id.ID)
+	if c.deleted || c.generation != id.Generation {
 		return component{}, false
 	}
-	return m.get(id), true
+	return c, true
 }
 
 func (m *componentTypeManager) getDataPtr(id int) *interface{} {
 	return &m.components[id/componentBlockSize][id%componentBlockSize].data
 }
 
-func (m *componentTypeManager) new(eID EntityID, data interface{}) int {
-	// If another block is needed
-	if len(m.components) <= m.len/componentBlockSize {
-		m.components = append(m.components, componentBlock{})
+func (m *componentTypeManager) getTicksPtr(id int) *ComponentTicks {
+	return &m.components[id/componentBlockSize][id%componentBlockSize].ticks
+}
+
+func (m *componentTypeManager) new(eID EntityID, data interface{}, tick uint64) (int, uint32) {
+	var id int
+	var generation uint32
+	// If there is a deleted component that can be reused
+	if len(m.free) > 0 {
+		id = m.free[len(m.free)-1]
+		m.free = m.free[:len(m.free)-1]
+		generation = m.get(id).generation + 1
+	} else {
+		// If another block is needed
+		if len(m.components) <= m.len/componentBlockSize {
+			m.components = append(m.components, &componentBlock{})
+		}
+		id = m.len
+		m.len++
 	}
-	id := m.len
 	m.components[id/componentBlockSize][id%componentBlockSize] = component{
-		entity: eID,
-		data:   data,
+		entity:     eID,
+		data:       data,
+		ticks:      ComponentTicks{Added: tick, Changed: tick},
+		generation: generation,
 	}
-	m.len++
-	return id
+	return id, generation
 }
 
 func (m *componentTypeManager) delete(id int) {
-	m.components[id/componentBlockSize][id%componentBlockSize].deleted = true
-
-	// If this is the last component
-	if id == m.len-1 {
-		// Find the last non deleted component
-		var end int
-		for end = id; end > 0 && m.get(end).deleted; end-- {
-			// If the deleted component is at the start of the block
-			if end%componentBlockSize == 0 {
-				// Delete the last block
-				m.components = m.components[:len(m.components)-1]
-			}
-		}
-		m.len = end
+	c := &m.components[id/componentBlockSize][id%componentBlockSize]
+	if c.deleted {
+		return
+	}
+	c.deleted = true
+	// Don't keep the data alive
+	c.data = nil
+	m.free = append(m.free, id)
+}
+
+func (m *componentTypeManager) stats() SlotStats {
+	return SlotStats{
+		Live:     m.len - len(m.free),
+		Free:     len(m.free),
+		Capacity: len(m.components) * componentBlockSize,
 	}
 }
 
+// SlotStats is a report of the slots used to store entities or components of a single type
+type SlotStats struct {
+	// Live is the number of slots in use
+	Live int
+	// Free is the number of deleted slots waiting to be reused
+	Free int
+	// Capacity is the number of slots allocated, including ones that have never been used
+	Capacity int
+}
+
+// Stats is a report of the memory used by an EntityComponentManager
+type Stats struct {
+	Entities   SlotStats
+	Components map[ComponentTypeID]SlotStats
+}
+
 type ComponentCallback func(Entity)
 
 // EntityComponentManager manages all the entities and components. This is a single object as an
@@ -205,7 +371,7 @@ type EntityComponentManager interface {
 	NewEntity(name string) EntityID
 
 	// NewComponent creates a new component of the given type in the given entity and returns its
-	// ID
+	// ID. Returns an error if the entity isn't alive
 	NewComponent(EntityID, ComponentTypeID, interface{}) (ComponentID, error)
 
 	// NewComponentReflect creates a new component, and determines the component type ID of data
@@ -221,23 +387,45 @@ type EntityComponentManager interface {
 	// of the iterator. Otherwise returns true, nil
 	ForEntities(func(Entity) (bool, error)) (bool, error)
 
-	// GetEntity returns the entity
+	// IsAlive returns whether the given entity exists. Returns false for IDs of deleted entities,
+	// even if their index has since been reused
+	IsAlive(EntityID) bool
+
+	// GetEntity returns the entity. If the entity isn't alive, the returned entity has no
+	// components
 	GetEntity(EntityID) Entity
 
+	// GetEntitySafe returns a combination of GetEntity and IsAlive
+	GetEntitySafe(EntityID) (Entity, bool)
+
 	// GetEntityIDs gets the IDs of all the entities with the given component types
 	GetEntityIDs(actsOn []ComponentTypeID) []EntityID
 
 	// GetEntities gets all the entities with the given component types
 	GetEntities([]ComponentTypeID) []Entity
 
-	// GetComponent returns the component with the given ComponentID
+	// QueryEntityIDs gets the IDs of all the entities matching the given query
+	QueryEntityIDs(Query) []EntityID
+
+	// QueryEntities gets all the entities matching the given query
+	QueryEntities(Query) []Entity
+
+	// GetComponent returns the component with the given ComponentID. Returns nil if the component
+	// doesn't exist
 	GetComponent(ComponentID) interface{}
 
-	// UpdateComponent updates the given component with the given ComponentID
+	// UpdateComponent updates the given component with the given ComponentID. If the component
+	// doesn't exist this is a no-op
 	UpdateComponent(ComponentID, interface{})
 
+	// UpdateComponentFunc calls the given function with a pointer to the data of the component
+	// with the given ComponentID, so it can be modified in place. The lock on the component's
+	// storage is held until the function returns, so the function must not use the manager. If the
+	// component doesn't exist this is a no-op
+	UpdateComponentFunc(ComponentID, func(data *interface{}))
+
 	// DeleteEntity deletes the given entity's components. The entity itself will then be deleted
-	// when DeleteEmptyEntities is called. If the entity doesn't exist this is a no-op
+	// when DeleteEmptyEntities is called. If the entity isn't alive this is a no-op
 	DeleteEntity(EntityID)
 
 	// DeleteComponent deletes the given component and removes it from the entity. If the component
@@ -247,8 +435,22 @@ type EntityComponentManager interface {
 	// DeleteComponentCallback adds a callback function for when a component is deleted
 	DeleteComponentCallback(ComponentCallback)
 
-	// DeleteEmptyEntities deletes all entities that have no components
+	// DeleteEmptyEntities deletes all entities that have no components. The index of a deleted
+	// entity may be reused by NewEntity, but with a new generation
 	DeleteEmptyEntities()
+
+	// Stats returns the number of live, free and allocated slots for entities and for each
+	// component type
+	Stats() Stats
+
+	// ChangeTick returns the current change tick. Creating or updating a component sets its
+	// ComponentTicks to the current tick, and deleting one records the tick in its entity
+	ChangeTick() uint64
+
+	// AdvanceChangeTick increments the change tick and returns the new tick. The system manager
+	// calls this before and after systems run, so they can tell which components changed since
+	// they last ran
+	AdvanceChangeTick() uint64
 }
 
 type entityComponentManager struct {
@@ -256,25 +458,33 @@ type entityComponentManager struct {
 	componentTypes        map[reflect.Type]ComponentTypeID
 	componentTypeManagers map[ComponentTypeID]*componentTypeManager
 
-	entityLock         sync.RWMutex
-	entities           []entity
+	entityLock sync.RWMutex
+	entities   []entity
+	// The indices of deleted entities, which will be reused before entities grows
+	freeEntities       []int
 	entitiesToBeKilled map[EntityID]struct{}
 
 	newComponentCallbacks    []ComponentCallback
 	deleteComponentCallbacks []ComponentCallback
+
+	tick atomic.Uint64
 }
 
 func newEntityComponentManager() *entityComponentManager {
-	return &entityComponentManager{
+	m := &entityComponentManager{
 		componentTypes:        make(map[reflect.Type]ComponentTypeID),
 		componentTypeManagers: make(map[ComponentTypeID]*componentTypeManager),
 
 		entities:           make([]entity, 0),
+		freeEntities:       make([]int, 0),
 		entitiesToBeKilled: make(map[EntityID]struct{}),
 
 		newComponentCallbacks:    make([]ComponentCallback, 0),
 		deleteComponentCallbacks: make([]ComponentCallback, 0),
 	}
+	// Start after 0, so components created before a system first runs count as added
+	m.tick.Store(1)
+	return m
 }
 
 // NewEntityComponentManager creates and returns an entity component manager
@@ -286,11 +496,28 @@ func (m *entityComponentManager) NewEntity(name string) EntityID {
 	m.entityLock.Lock()
 	defer m.entityLock.Unlock()
 
-	id := EntityID(len(m.entities))
-	m.entities = append(m.entities, entity{
-		name:       name,
-		components: make(map[ComponentTypeID]componentPtr),
-	})
+	var id EntityID
+	// If there is a deleted entity that can be reused
+	if len(m.freeEntities) > 0 {
+		index := m.freeEntities[len(m.freeEntities)-1]
+		m.freeEntities = m.freeEntities[:len(m.freeEntities)-1]
+		generation := m.entities[index].generation + 1
+		id = newEntityID(index, generation)
+		m.entities[index] = entity{
+			name:       name,
+			components: make(componentPtrs),
+			removed:    make(map[ComponentTypeID]uint64),
+			generation: generation,
+		}
+	} else {
+		id = newEntityID(len(m.entities), 0)
+		m.entities = append(m.entities, entity{
+			name:       name,
+			components: make(componentPtrs),
+			removed:    make(map[ComponentTypeID]uint64),
+		})
+	}
+
 	// The entity is empty, so it will be killed (if it isn't given a component)
 	m.entitiesToBeKilled[id] = struct{}{}
 	return id
@@ -315,10 +542,14 @@ func (m *entityComponentManager) NewComponent(eID EntityID,
 		m.entityLock.Lock()
 		defer m.entityLock.Unlock()
 
+		if !m.isAlive(eID) {
+			return ComponentID{}, entity{}, fmt.Errorf("entity %d is not alive", eID)
+		}
+
 		// Check for duplicate types
-		_, ok = m.entities[eID].components[cType]
+		_, ok = m.entities[eID.Index()].components[cType]
 		if ok {
-			return ComponentID{}, m.entities[eID], fmt.Errorf(
+			return ComponentID{}, m.entities[eID.Index()], fmt.Errorf(
 				"two components of the same type (%s) in entity %d", cType.String(), eID)
 		}
 
@@ -326,22 +557,27 @@ func (m *entityComponentManager) NewComponent(eID EntityID,
 		defer typeManager.Unlock()
 
 		// Create the component
+		cID, generation := typeManager.new(eID, data, m.tick.Load())
 		id := ComponentID{
-			ID:              typeManager.new(eID, data),
+			ID:              cID,
 			ComponentTypeID: cType,
+			Generation:      generation,
 		}
 
 		// Add the component to the entity
-		m.entities[eID].components[cType] = componentPtr{
-			RWMutex: &typeManager.RWMutex,
-			id:      id.ID,
-			ptr:     typeManager.getDataPtr(id.ID),
+		m.entities[eID.Index()].components[cType] = componentPtr{
+			RWMutex:    &typeManager.RWMutex,
+			id:         id.ID,
+			generation: generation,
+			ptr:        typeManager.getDataPtr(id.ID),
+			ticks:      typeManager.getTicksPtr(id.ID),
+			tick:       &m.tick,
 		}
 
 		// Make sure the entity won't be deleted
 		delete(m.entitiesToBeKilled, eID)
 
-		return id, m.entities[eID], nil
+		return id, m.entities[eID.Index()], nil
 	}()
 	if err != nil {
 		return id, err
@@ -372,9 +608,12 @@ func (m *entityComponentManager) NewComponentCallback(c ComponentCallback) {
 // GetComponent (which has locking)
 func (m *entityComponentManager) newEntity(id EntityID, e entity) Entity {
 	return Entity{
-		id:         id,
-		name:       e.name,
-		components: e.components,
+		id:   id,
+		name: e.name,
+		components: entityComponentPtrs{
+			componentPtrs: e.components,
+			removedTicks:  e.removed,
+		},
 	}
 }
 
@@ -396,10 +635,26 @@ func (m *entityComponentManager) getComponentTypeManagerSafe(
 	return manager, ok
 }
 
+// Returns the ID of the entity at the given index. Doesn't lock entityLock
+func (m *entityComponentManager) entityID(index int) EntityID {
+	return newEntityID(index, m.entities[index].generation)
+}
+
+// Returns whether the entity is alive. Doesn't lock entityLock
+func (m *entityComponentManager) isAlive(id EntityID) bool {
+	index := id.Index()
+	return index < len(m.entities) &&
+		!m.entities[index].deleted &&
+		m.entities[index].generation == id.Generation()
+}
+
 func (m *entityComponentManager) ForEntities(i func(Entity) (bool, error)) (bool, error) {
 	m.entityLock.RLock()
-	for id, entity := range m.entities {
-		e := m.newEntity(EntityID(id), entity)
+	for index := 0; index < len(m.entities); index++ {
+		if m.entities[index].deleted {
+			continue
+		}
+		e := m.newEntity(m.entityID(index), m.entities[index])
 		m.entityLock.RUnlock()
 		ok, err := i(e)
 		if !ok || err != nil {
@@ -411,91 +666,146 @@ func (m *entityComponentManager) ForEntities(i func(Entity) (bool, error)) (bool
 	return true, nil
 }
 
-func (m *entityComponentManager) GetEntity(id EntityID) Entity {
+func (m *entityComponentManager) IsAlive(id EntityID) bool {
 	m.entityLock.RLock()
 	defer m.entityLock.RUnlock()
-	return m.newEntity(id, m.entities[id])
+	return m.isAlive(id)
 }
 
-func entityHasComponents(entity entity, components []ComponentTypeID) bool {
-	// Check if the entity has all the correct components
-	for _, cType := range components {
-		_, ok := entity.components[cType]
-		if !ok {
-			return false
-		}
+func (m *entityComponentManager) GetEntity(id EntityID) Entity {
+	e, _ := m.GetEntitySafe(id)
+	return e
+}
+
+func (m *entityComponentManager) GetEntitySafe(id EntityID) (Entity, bool) {
+	m.entityLock.RLock()
+	defer m.entityLock.RUnlock()
+	if !m.isAlive(id) {
+		return Entity{id: id}, false
 	}
-	return true
+	return m.newEntity(id, m.entities[id.Index()]), true
 }
 
 func (m *entityComponentManager) GetEntityIDs(actsOn []ComponentTypeID) []EntityID {
+	return m.QueryEntityIDs(NewQuery().With(actsOn...))
+}
+
+func (m *entityComponentManager) GetEntities(actsOn []ComponentTypeID) []Entity {
+	return m.QueryEntities(NewQuery().With(actsOn...))
+}
+
+func (m *entityComponentManager) QueryEntityIDs(q Query) []EntityID {
 	m.entityLock.RLock()
 	defer m.entityLock.RUnlock()
 
 	entities := make([]EntityID, 0)
 
-	for id, entity := range m.entities {
-		// Check if the entity has all the correct components
-		if entityHasComponents(entity, actsOn) {
-			// Add the entity
-			entities = append(entities, EntityID(id))
+	for index, entity := range m.entities {
+		// Check if the entity matches the query
+		if entity.deleted || !q.matches(entity.components.has) {
+			continue
+		}
+		id := m.entityID(index)
+		if q.hasChangeFilters() && !q.matchesChanges(m.newEntity(id, entity), q.since) {
+			continue
 		}
+		// Add the entity
+		entities = append(entities, id)
 	}
 
 	return entities
 }
 
-func (m *entityComponentManager) GetEntities(actsOn []ComponentTypeID) []Entity {
+func (m *entityComponentManager) QueryEntities(q Query) []Entity {
 	m.entityLock.RLock()
 	defer m.entityLock.RUnlock()
 
 	entities := make([]Entity, 0)
 
-	for id, entity := range m.entities {
-		// Check if the entity has all the correct components
-		if entityHasComponents(entity, actsOn) {
+	for index, entity := range m.entities {
+		// Check if the entity matches the query
+		if !entity.deleted && q.matches(entity.components.has) {
 			// Add the entity
-			entities = append(entities, m.newEntity(EntityID(id), entity))
+			entities = append(entities, m.newEntity(m.entityID(index), entity))
 		}
 	}
 
-	return entities
+	return q.filterChanges(entities)
 }
 
 func (m *entityComponentManager) GetComponent(id ComponentID) interface{} {
-	typeManager := m.getComponentTypeManager(id.ComponentTypeID)
+	typeManager, ok := m.getComponentTypeManagerSafe(id.ComponentTypeID)
+	if !ok {
+		return nil
+	}
 	typeManager.RLock()
 	defer typeManager.RUnlock()
-	return typeManager.get(id.ID).data
+	c, _ := typeManager.getSafe(id)
+	return c.data
 }
 
 func (m *entityComponentManager) UpdateComponent(id ComponentID, data interface{}) {
-	typeManager := m.getComponentTypeManager(id.ComponentTypeID)
+	typeManager, ok := m.getComponentTypeManagerSafe(id.ComponentTypeID)
+	if !ok {
+		return
+	}
 	typeManager.Lock()
 	defer typeManager.Unlock()
 
+	if _, ok := typeManager.getSafe(id); !ok {
+		return
+	}
 	*typeManager.getDataPtr(id.ID) = data
+	typeManager.getTicksPtr(id.ID).Changed = m.tick.Load()
 }
 
-func (m *entityComponentManager) DeleteEntity(id EntityID) {
-	m.entityLock.Lock()
-	defer m.entityLock.Unlock()
-
-	if int(id) >= len(m.entities) {
+func (m *entityComponentManager) UpdateComponentFunc(id ComponentID, f func(data *interface{})) {
+	typeManager, ok := m.getComponentTypeManagerSafe(id.ComponentTypeID)
+	if !ok {
 		return
 	}
+	typeManager.Lock()
+	defer typeManager.Unlock()
 
-	// Delete the entity's components
-	for cType, c := range m.entities[id].components {
-		typeManager := m.getComponentTypeManager(cType)
-		typeManager.Lock()
-		typeManager.delete(c.id)
-		typeManager.Unlock()
-		delete(m.entities[id].components, cType)
+	if _, ok := typeManager.getSafe(id); !ok {
+		return
 	}
+	f(typeManager.getDataPtr(id.ID))
+	typeManager.getTicksPtr(id.ID).Changed = m.tick.Load()
+}
 
-	// Set the entity has to be killed
-	m.entitiesToBeKilled[id] = struct{}{}
+func (m *entityComponentManager) DeleteEntity(id EntityID) {
+	entity, deleted := func() (entity, bool) {
+		m.entityLock.Lock()
+		defer m.entityLock.Unlock()
+
+		if !m.isAlive(id) {
+			return entity{}, false
+		}
+
+		// Delete the entity's components
+		for cType, c := range m.entities[id.Index()].components {
+			typeManager := m.getComponentTypeManager(cType)
+			typeManager.Lock()
+			typeManager.delete(c.id)
+			typeManager.Unlock()
+			delete(m.entities[id.Index()].components, cType)
+			m.entities[id.Index()].removed[cType] = m.tick.Load()
+		}
+
+		// Set the entity has to be killed
+		m.entitiesToBeKilled[id] = struct{}{}
+
+		return m.entities[id.Index()], true
+	}()
+
+	// If the entity was actually deleted
+	if deleted {
+		// Run the callbacks, so anything tracking the entity knows it has no components
+		for _, callback := range m.deleteComponentCallbacks {
+			callback(m.newEntity(id, entity))
+		}
+	}
 }
 
 func (m *entityComponentManager) DeleteComponent(id ComponentID) {
@@ -512,7 +822,7 @@ func (m *entityComponentManager) DeleteComponent(id ComponentID) {
 		defer typeManager.Unlock()
 
 		// Get the component
-		c, ok := typeManager.getSafe(id.ID)
+		c, ok := typeManager.getSafe(id)
 		if !ok {
 			return component{}, entity{}, false
 		}
@@ -521,15 +831,16 @@ func (m *entityComponentManager) DeleteComponent(id ComponentID) {
 		typeManager.delete(id.ID)
 
 		// Delete the component from the entity
-		delete(m.entities[c.entity].components, id.ComponentTypeID)
+		delete(m.entities[c.entity.Index()].components, id.ComponentTypeID)
+		m.entities[c.entity.Index()].removed[id.ComponentTypeID] = m.tick.Load()
 
 		// If the entity is now empty
-		if len(m.entities[c.entity].components) == 0 {
+		if len(m.entities[c.entity.Index()].components) == 0 {
 			// Kill it
 			m.entitiesToBeKilled[c.entity] = struct{}{}
 		}
 
-		return c, m.entities[c.entity], true
+		return c, m.entities[c.entity.Index()], true
 	}()
 
 	// If the component was actually deleted
@@ -546,25 +857,54 @@ func (m *entityComponentManager) DeleteComponentCallback(c ComponentCallback) {
 }
 
 func (m *entityComponentManager) DeleteEmptyEntities() {
-	deletedLast := false
-	for id := range m.entitiesToBeKilled {
-		// Delete the entity
-		m.entities[id].deleted = true
-		delete(m.entitiesToBeKilled, id)
+	m.entityLock.Lock()
+	defer m.entityLock.Unlock()
 
-		// If this is the "last" entity
-		if int(id) == len(m.entities)-1 {
-			deletedLast = true
+	// In order, so the indices are reused in the same order every time
+	for _, id := range sortedEntityIDs(m.entitiesToBeKilled) {
+		delete(m.entitiesToBeKilled, id)
+		if !m.isAlive(id) {
+			continue
 		}
+
+		// Delete the entity, and let its index be reused
+		m.entities[id.Index()].deleted = true
+		m.entities[id.Index()].components = nil
+		m.freeEntities = append(m.freeEntities, id.Index())
 	}
+}
+
+func (m *entityComponentManager) Stats() Stats {
+	stats := Stats{}
 
-	// If the last entity (entity with the largest ID) was deleted
-	if deletedLast {
-		// Find the last non deleted entity
-		var end int
-		for end = len(m.entities) - 1; end > 0 && m.entities[end].deleted; end-- {
+	// Get the entity stats in an anonymous function so entityLock unlocks early
+	func() {
+		m.entityLock.RLock()
+		defer m.entityLock.RUnlock()
+		stats.Entities = SlotStats{
+			Live:     len(m.entities) - len(m.freeEntities),
+			Free:     len(m.freeEntities),
+			Capacity: len(m.entities),
 		}
-		// Delete the last entities
-		m.entities = m.entities[:end]
+	}()
+
+	m.componentLock.RLock()
+	defer m.componentLock.RUnlock()
+
+	stats.Components = make(map[ComponentTypeID]SlotStats, len(m.componentTypeManagers))
+	for cType, typeManager := range m.componentTypeManagers {
+		typeManager.RLock()
+		stats.Components[cType] = typeManager.stats()
+		typeManager.RUnlock()
 	}
+
+	return stats
+}
+
+func (m *entityComponentManager) ChangeTick() uint64 {
+	return m.tick.Load()
+}
+
+func (m *entityComponentManager) AdvanceChangeTick() uint64 {
+	return m.tick.Add(1)
 }
@@ -29,6 +29,186 @@ func newComponent3(m EntityComponentManager, entity EntityID) (ComponentID, erro
 	return m.NewComponent(entity, componentType3, component3Value)
 }
 
+// The EntityComponentManager implementations, which the tests that only use the interface are run
+// against
+var entityComponentManagers = []struct {
+	name string
+	new  func() EntityComponentManager
+}{
+	{"default", NewEntityComponentManager},
+	{"archetype", NewArchetypeEntityComponentManager},
+}
+
+// Runs the given test as a subtest for each EntityComponentManager implementation
+func testEntityComponentManagers(t *testing.T,
+	test func(a *assert.Assertions, m EntityComponentManager)) {
+	for _, impl := range entityComponentManagers {
+		t.Run(impl.name, func(t *testing.T) {
+			test(assert.New(t), impl.new())
+		})
+	}
+}
+
+// TestEntityComponentManager_Implementations runs the behaviour that only uses the interface
+// against each EntityComponentManager implementation
+func TestEntityComponentManager_Implementations(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		test func(a *assert.Assertions, m EntityComponentManager)
+	}{
+		{"NewEntity", func(a *assert.Assertions, m EntityComponentManager) {
+			entity := m.NewEntity("entity")
+			a.True(m.IsAlive(entity))
+			a.Equal("entity", m.GetEntity(entity).Name())
+			a.Len(m.GetEntity(entity).Components(), 0)
+
+			// The entity is empty, so it should be killed
+			m.DeleteEmptyEntities()
+			a.False(m.IsAlive(entity))
+		}},
+		{"NewComponent", func(a *assert.Assertions, m EntityComponentManager) {
+			entityID := m.NewEntity("entity")
+			id, err := newComponent1(m, entityID)
+			a.NoError(err)
+			a.Equal(componentType1, id.ComponentTypeID)
+			a.Equal(Component{id, component1Value}, m.GetEntity(entityID).Get(componentType1))
+			a.Len(m.GetEntity(entityID).Components(), 1)
+
+			// The entity isn't empty, so it shouldn't be killed
+			m.DeleteEmptyEntities()
+			a.True(m.IsAlive(entityID))
+
+			// Duplicate component types
+			_, err = newComponent1(m, entityID)
+			a.Error(err)
+		}},
+		{"GetEntity", func(a *assert.Assertions, m EntityComponentManager) {
+			entityID := m.NewEntity("entity")
+			id1, err := newComponent1(m, entityID)
+			a.NoError(err)
+			id2, err := newComponent2(m, entityID)
+			a.NoError(err)
+
+			entity := m.GetEntity(entityID)
+			a.Equal(entityID, entity.ID())
+			a.Len(entity.Components(), 2)
+			a.Equal(Component{id1, component1Value}, entity.Get(componentType1))
+			a.Equal(Component{id2, component2Value}, entity.Get(componentType2))
+			a.Equal(1, m.GetComponent(id1))
+		}},
+		{"DeleteEntity", func(a *assert.Assertions, m EntityComponentManager) {
+			entity := m.NewEntity("entity")
+			_, err := newComponent1(m, entity)
+			a.NoError(err)
+			m.DeleteEntity(entity)
+			a.Len(m.GetEntity(entity).Components(), 0)
+
+			// The entity should be killed
+			m.DeleteEmptyEntities()
+			a.False(m.IsAlive(entity))
+
+			// Deleting an entity that doesn't exist should be a no-op
+			m.DeleteEntity(100)
+		}},
+		{"DeleteComponent", func(a *assert.Assertions, m EntityComponentManager) {
+			entity := m.NewEntity("entity")
+			id, err := newComponent1(m, entity)
+			a.NoError(err)
+			m.DeleteComponent(id)
+			a.Len(m.GetEntity(entity).Components(), 0)
+			a.Equal(0, m.Stats().Components[componentType1].Live)
+			a.Equal(1, m.Stats().Components[componentType1].Free)
+
+			id1, err := newComponent2(m, entity)
+			a.NoError(err)
+			id2, err := newComponent3(m, entity)
+			a.NoError(err)
+			m.DeleteComponent(id1)
+			a.Len(m.GetEntity(entity).Components(), 1)
+			a.Equal(id2, m.GetEntity(entity).Get(componentType3).ID())
+
+			// Deleting a component that was already deleted is a no op, even if its ID has been
+			// reused
+			id3, err := newComponent2(m, entity)
+			a.NoError(err)
+			a.Equal(id1.ID, id3.ID)
+			m.DeleteComponent(id1)
+			a.Equal(id3, m.GetEntity(entity).Get(componentType2).ID())
+
+			// A stale ID doesn't refer to the new component
+			a.Nil(m.GetComponent(id1))
+			m.UpdateComponent(id1, 2.0)
+			a.Equal(component2Value, m.GetComponent(id3))
+		}},
+		{"DeleteEmptyEntities", func(a *assert.Assertions, m EntityComponentManager) {
+			entity1 := m.NewEntity("entity")
+			entity2 := m.NewEntity("entity")
+			_, err := newComponent1(m, entity2)
+			a.NoError(err)
+
+			m.DeleteEmptyEntities()
+			a.False(m.IsAlive(entity1))
+			a.True(m.IsAlive(entity2))
+
+			m.DeleteEntity(entity2)
+			a.True(m.IsAlive(entity2))
+			m.DeleteEmptyEntities()
+			a.False(m.IsAlive(entity2))
+
+			// Deleted entities shouldn't be iterated over
+			_, _ = m.ForEntities(func(Entity) (bool, error) {
+				a.Fail("entity should have been deleted")
+				return true, nil
+			})
+
+			// The deleted entities should be reused
+			entity3 := m.NewEntity("entity")
+			entity4 := m.NewEntity("entity")
+			a.ElementsMatch([]int{entity1.Index(), entity2.Index()},
+				[]int{entity3.Index(), entity4.Index()})
+			a.Equal(SlotStats{Live: 2, Free: 0, Capacity: 2}, m.Stats().Entities)
+		}},
+		{"IsAlive", func(a *assert.Assertions, m EntityComponentManager) {
+			entity1 := m.NewEntity("entity")
+			a.True(m.IsAlive(entity1))
+			m.DeleteEmptyEntities()
+			a.False(m.IsAlive(entity1))
+
+			// The index should be reused with a new generation
+			entity2 := m.NewEntity("entity")
+			a.Equal(entity1.Index(), entity2.Index())
+			a.NotEqual(entity1.Generation(), entity2.Generation())
+			a.False(m.IsAlive(entity1))
+			a.True(m.IsAlive(entity2))
+			a.False(m.IsAlive(100))
+		}},
+		{"StaleEntityID", func(a *assert.Assertions, m EntityComponentManager) {
+			stale := m.NewEntity("stale")
+			_, err := newComponent1(m, stale)
+			a.NoError(err)
+			m.DeleteEntity(stale)
+			m.DeleteEmptyEntities()
+
+			entityID := m.NewEntity("entity")
+			_, err = newComponent1(m, entityID)
+			a.NoError(err)
+
+			// A stale ID shouldn't refer to the new entity
+			_, ok := m.GetEntitySafe(stale)
+			a.False(ok)
+			a.False(m.GetEntity(stale).Has(componentType1))
+			_, err = newComponent2(m, stale)
+			a.Error(err)
+			m.DeleteEntity(stale)
+			a.True(m.GetEntity(entityID).Has(componentType1))
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			testEntityComponentManagers(t, test.test)
+		})
+	}
+}
+
 func TestEntityComponentManager_NewEntity(t *testing.T) {
 	a := assert.New(t)
 	m := newEntityComponentManager()
@@ -82,11 +262,36 @@ func TestEntityComponentManager_GetEntity(t *testing.T) {
 
 	entity := m.GetEntity(entityID)
 	a.Equal(entityID, entity.ID())
-	a.Len(entity.components, 2)
+	a.Len(entity.Components(), 2)
 	a.Equal(Component{id1, component1Value}, entity.Get(componentType1))
 	a.Equal(Component{id2, component2Value}, entity.Get(componentType2))
 }
 
+func TestEntityComponentManager_GetEntities(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		entityID1 := m.NewEntity("entity")
+		_, err := newComponent1(m, entityID1)
+		a.NoError(err)
+		_, err = newComponent2(m, entityID1)
+		a.NoError(err)
+
+		entityID2 := m.NewEntity("entity")
+		_, err = newComponent1(m, entityID2)
+		a.NoError(err)
+
+		a.ElementsMatch([]EntityID{entityID1, entityID2},
+			m.GetEntityIDs([]ComponentTypeID{componentType1}))
+		a.ElementsMatch([]EntityID{entityID1},
+			m.GetEntityIDs([]ComponentTypeID{componentType1, componentType2}))
+		a.Empty(m.GetEntityIDs([]ComponentTypeID{componentType3}))
+
+		entities := m.GetEntities([]ComponentTypeID{componentType2})
+		a.Len(entities, 1)
+		a.Equal(entityID1, entities[0].ID())
+		a.True(entities[0].Has(componentType2))
+	})
+}
+
 func TestEntityComponentManager_GetComponent(t *testing.T) {
 	a := assert.New(t)
 	m := newEntityComponentManager()
@@ -98,6 +303,18 @@ func TestEntityComponentManager_GetComponent(t *testing.T) {
 	a.Equal(1, m.GetComponent(id))
 }
 
+func TestEntityComponentManager_UpdateComponent(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		entityID := m.NewEntity("entity")
+
+		id, err := newComponent1(m, entityID)
+		a.NoError(err)
+		m.UpdateComponent(id, 2)
+		a.Equal(2, m.GetComponent(id))
+		a.Equal(2, m.GetEntity(entityID).Get(componentType1).Data)
+	})
+}
+
 func TestEntityComponentManager_BulkNewEntitiesAndComponents(t *testing.T) {
 	a := assert.New(t)
 	m := newEntityComponentManager()
@@ -118,6 +335,37 @@ func TestEntityComponentManager_BulkNewEntitiesAndComponents(t *testing.T) {
 	}
 }
 
+func TestEntityComponentManager_UpdateComponentFunc(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		entityID := m.NewEntity("entity")
+
+		id, err := newComponent1(m, entityID)
+		a.NoError(err)
+		m.UpdateComponentFunc(id, func(data *interface{}) {
+			*data = (*data).(int) + 1
+		})
+		a.Equal(2, m.GetComponent(id))
+
+		// Updating through the entity
+		entity := m.GetEntity(entityID)
+		a.True(entity.UpdateFunc(componentType1, func(data *interface{}) {
+			*data = (*data).(int) + 1
+		}))
+		a.Equal(3, m.GetComponent(id))
+
+		// Updating a component the entity doesn't have
+		a.False(entity.UpdateFunc(componentType2, func(*interface{}) {
+			a.Fail("update function shouldn't be called")
+		}))
+
+		// Updating a component that doesn't exist is a no-op
+		m.DeleteComponent(id)
+		m.UpdateComponentFunc(id, func(*interface{}) {
+			a.Fail("update function shouldn't be called")
+		})
+	})
+}
+
 func TestEntityComponentManager_DeleteEntity(t *testing.T) {
 	a := assert.New(t)
 	m := newEntityComponentManager()
@@ -146,13 +394,13 @@ func TestEntityComponentManager_componentTypeManager(t *testing.T) {
 
 	a.False(m.components[0][0].deleted)
 
-	id1 := m.new(0, 0)
+	id1, _ := m.new(0, 0, 0)
 	a.Equal(1, m.len)
 	a.False(m.get(id1).deleted)
 	a.Equal(EntityID(0), m.get(id1).entity)
 	a.Equal(0, m.get(id1).data)
 
-	id2 := m.new(1, 1)
+	id2, _ := m.new(1, 1, 0)
 	a.Equal(2, m.len)
 	a.False(m.get(id2).deleted)
 	a.Equal(EntityID(1), m.get(id2).entity)
@@ -162,27 +410,51 @@ func TestEntityComponentManager_componentTypeManager(t *testing.T) {
 	a.Equal(2, m.len)
 	a.True(m.get(id1).deleted)
 	a.False(m.get(id2).deleted)
+	a.Equal([]int{id1}, m.free)
+
+	// Deleting twice is a no-op
+	m.delete(id1)
+	a.Equal([]int{id1}, m.free)
+
+	// The deleted component should be reused, with a new generation
+	id3, generation := m.new(2, 2, 0)
+	a.Equal(id1, id3)
+	a.Equal(uint32(1), generation)
+	a.Equal(2, m.len)
+	a.False(m.get(id3).deleted)
+	a.Equal(EntityID(2), m.get(id3).entity)
+	a.Len(m.free, 0)
+	_, ok := m.getSafe(ComponentID{ID: id1, Generation: 0})
+	a.False(ok)
+	_, ok = m.getSafe(ComponentID{ID: id3, Generation: generation})
+	a.True(ok)
 
 	m.delete(id2)
-	a.Equal(0, m.len)
-	a.True(m.get(id1).deleted)
-	a.True(m.get(id2).deleted)
+	m.delete(id3)
+	a.Equal(SlotStats{Live: 0, Free: 2, Capacity: componentBlockSize}, m.stats())
 
 	for i := 0; i < componentBlockSize+1; i++ {
-		m.new(EntityID(i), 1)
-		a.False(m.get(i).deleted)
+		m.new(EntityID(i), 1, 0)
 	}
 	a.Len(m.components, 2)
+	a.Equal(componentBlockSize+1, m.len)
+	a.Equal(SlotStats{Live: componentBlockSize + 1, Free: 0, Capacity: componentBlockSize * 2},
+		m.stats())
 
+	// Deleting components in the middle should free them for reuse
 	for i := 0; i < componentBlockSize; i++ {
 		m.delete(i)
 	}
 	a.Len(m.components, 2)
-	a.Equal(componentBlockSize+1, m.len)
+	a.Equal(SlotStats{Live: 1, Free: componentBlockSize, Capacity: componentBlockSize * 2},
+		m.stats())
 
-	m.delete(componentBlockSize)
-	a.Len(m.components, 1)
-	a.Equal(0, m.len)
+	for i := 0; i < componentBlockSize; i++ {
+		id, _ := m.new(EntityID(i), 1, 0)
+		a.Less(id, componentBlockSize)
+	}
+	a.Len(m.components, 2)
+	a.Equal(componentBlockSize+1, m.len)
 }
 
 func TestEntityComponentManager_DeleteComponent(t *testing.T) {
@@ -197,7 +469,8 @@ func TestEntityComponentManager_DeleteComponent(t *testing.T) {
 
 	// Check the component has been deleted in the memory
 	a.True(m.componentTypeManagers[componentType1].get(id.ID).deleted)
-	a.Equal(0, m.componentTypeManagers[componentType1].len)
+	a.Equal(SlotStats{Live: 0, Free: 1, Capacity: componentBlockSize},
+		m.componentTypeManagers[componentType1].stats())
 	a.Len(m.entities[entity].components, 0)
 
 	// The entity should be killed
@@ -223,6 +496,20 @@ func TestEntityComponentManager_DeleteComponent(t *testing.T) {
 		ID:              100,
 		ComponentTypeID: componentType1,
 	})
+
+	// Deleting a component that was already deleted is a no op, even if its ID has been reused
+	id3, err := newComponent2(m, entity)
+	a.NoError(err)
+	a.Equal(id1.ID, id3.ID)
+	m.DeleteComponent(id1)
+	a.Len(m.entities[entity].components, 2)
+	a.Equal(id3.ID, m.entities[entity].components[componentType2].id)
+	a.Equal(id3.Generation, m.entities[entity].components[componentType2].generation)
+
+	// A stale ID doesn't refer to the new component
+	a.Nil(m.GetComponent(id1))
+	m.UpdateComponent(id1, 2.0)
+	a.Equal(component2Value, m.GetComponent(id3))
 }
 
 func TestEntityComponentManager_DeleteEmptyEntities(t *testing.T) {
@@ -244,5 +531,135 @@ func TestEntityComponentManager_DeleteEmptyEntities(t *testing.T) {
 	m.DeleteEntity(entity2)
 
 	m.DeleteEmptyEntities()
-	a.Len(m.entities, 0)
+	a.Len(m.entities, 2)
+	a.True(m.entities[entity2].deleted)
+	a.ElementsMatch([]int{entity1.Index(), entity2.Index()}, m.freeEntities)
+
+	// The deleted entities should be reused
+	entity3 := m.NewEntity("entity")
+	entity4 := m.NewEntity("entity")
+	a.Len(m.entities, 2)
+	a.Len(m.freeEntities, 0)
+	a.ElementsMatch([]int{entity1.Index(), entity2.Index()},
+		[]int{entity3.Index(), entity4.Index()})
+}
+
+func TestEntityComponentManager_Stats(t *testing.T) {
+	a := assert.New(t)
+	m := newEntityComponentManager()
+
+	entity1 := m.NewEntity("entity")
+	_, err := newComponent1(m, entity1)
+	a.NoError(err)
+	_, err = newComponent2(m, entity1)
+	a.NoError(err)
+
+	entity2 := m.NewEntity("entity")
+	_, err = newComponent1(m, entity2)
+	a.NoError(err)
+
+	m.DeleteEntity(entity1)
+	m.DeleteEmptyEntities()
+
+	a.Equal(Stats{
+		Entities: SlotStats{Live: 1, Free: 1, Capacity: 2},
+		Components: map[ComponentTypeID]SlotStats{
+			componentType1: {Live: 1, Free: 1, Capacity: componentBlockSize},
+			componentType2: {Live: 0, Free: 1, Capacity: componentBlockSize},
+		},
+	}, m.Stats())
+}
+
+func TestEntityComponentManager_IsAlive(t *testing.T) {
+	a := assert.New(t)
+	m := newEntityComponentManager()
+
+	entity1 := m.NewEntity("entity")
+	a.True(m.IsAlive(entity1))
+
+	m.DeleteEmptyEntities()
+	a.False(m.IsAlive(entity1))
+
+	// The index should be reused with a new generation
+	entity2 := m.NewEntity("entity")
+	a.Equal(entity1.Index(), entity2.Index())
+	a.NotEqual(entity1.Generation(), entity2.Generation())
+	a.False(m.IsAlive(entity1))
+	a.True(m.IsAlive(entity2))
+
+	// Entities that were never created aren't alive
+	a.False(m.IsAlive(100))
+}
+
+func TestEntityComponentManager_StaleEntityID(t *testing.T) {
+	a := assert.New(t)
+	m := newEntityComponentManager()
+
+	stale := m.NewEntity("stale")
+	_, err := newComponent1(m, stale)
+	a.NoError(err)
+	m.DeleteEntity(stale)
+	m.DeleteEmptyEntities()
+
+	entityID := m.NewEntity("entity")
+	_, err = newComponent1(m, entityID)
+	a.NoError(err)
+
+	// A stale ID shouldn't refer to the new entity
+	entity, ok := m.GetEntitySafe(stale)
+	a.False(ok)
+	a.False(entity.Has(componentType1))
+	a.False(m.GetEntity(stale).Has(componentType1))
+
+	_, err = newComponent2(m, stale)
+	a.Error(err)
+	a.False(m.GetEntity(entityID).Has(componentType2))
+
+	m.DeleteEntity(stale)
+	a.True(m.GetEntity(entityID).Has(componentType1))
+}
+
+func TestEntityComponentManager_ChangeTick(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		a.Equal(uint64(1), m.ChangeTick())
+
+		entityID := m.NewEntity("entity")
+		id, err := newComponent1(m, entityID)
+		a.NoError(err)
+		ticks, ok := m.GetEntity(entityID).Ticks(componentType1)
+		a.True(ok)
+		a.Equal(ComponentTicks{Added: 1, Changed: 1}, ticks)
+
+		// Updating the component should only change the changed tick
+		a.Equal(uint64(2), m.AdvanceChangeTick())
+		m.UpdateComponent(id, 2)
+		ticks, _ = m.GetEntity(entityID).Ticks(componentType1)
+		a.Equal(ComponentTicks{Added: 1, Changed: 2}, ticks)
+
+		m.AdvanceChangeTick()
+		m.UpdateComponentFunc(id, func(*interface{}) {})
+		ticks, _ = m.GetEntity(entityID).Ticks(componentType1)
+		a.Equal(ComponentTicks{Added: 1, Changed: 3}, ticks)
+
+		m.AdvanceChangeTick()
+		m.GetEntity(entityID).UpdateFunc(componentType1, func(*interface{}) {})
+		ticks, _ = m.GetEntity(entityID).Ticks(componentType1)
+		a.Equal(ComponentTicks{Added: 1, Changed: 4}, ticks)
+
+		// Adding another component shouldn't change the first one's ticks
+		_, err = newComponent2(m, entityID)
+		a.NoError(err)
+		ticks, _ = m.GetEntity(entityID).Ticks(componentType1)
+		a.Equal(ComponentTicks{Added: 1, Changed: 4}, ticks)
+
+		_, ok = m.GetEntity(entityID).RemovedTick(componentType1)
+		a.False(ok)
+		m.AdvanceChangeTick()
+		m.DeleteComponent(id)
+		_, ok = m.GetEntity(entityID).Ticks(componentType1)
+		a.False(ok)
+		tick, ok := m.GetEntity(entityID).RemovedTick(componentType1)
+		a.True(ok)
+		a.Equal(uint64(5), tick)
+	})
 }
@@ -0,0 +1,74 @@
+package ecs
+
+import (
+	"fmt"
+)
+
+// SystemErrFunc is a system function that can fail
+type SystemErrFunc func(*ECS, Event, Entity) error
+
+// SystemError is an error returned by a system, with what the system was running on
+type SystemError struct {
+	SystemID
+	EntityID
+	// Whether the system failed on an entity. If false EntityID should be ignored, for example for
+	// errors from batch systems
+	HasEntity bool
+	EventTypeID
+	Err error
+}
+
+func (e *SystemError) Error() string {
+	if !e.HasEntity {
+		return fmt.Sprintf("system %d failed for event type %s: %v",
+			e.SystemID, e.EventTypeID, e.Err)
+	}
+	return fmt.Sprintf("system %d failed on entity %d for event type %s: %v",
+		e.SystemID, e.EntityID, e.EventTypeID, e.Err)
+}
+
+// Unwrap returns the error the system returned
+func (e *SystemError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorPolicy is what happens when a system returns an error
+type ErrorPolicy int
+
+const (
+	// ErrorContinue keeps running the systems, and returns all the errors together. This is the
+	// default
+	ErrorContinue ErrorPolicy = iota
+	// ErrorStop stops running systems at the first error, and returns it. Run puts the events it
+	// hadn't handled yet back in the queue, so they are handled by the next Run
+	ErrorStop
+	// ErrorDisable stops running the failing system and disables it, so it doesn't run again until
+	// it is enabled with SetSystemEnabled. The other systems keep running, and all the errors are
+	// returned together
+	ErrorDisable
+)
+
+// PanicError is a panic recovered from a system, which is returned wrapped in a SystemError
+type PanicError struct {
+	// The value the system panicked with
+	Value interface{}
+	// The stack trace of the goroutine when it panicked
+	Stack []byte
+}
+
+// Error returns the value the system panicked with. The stack trace isn't included, but is kept in
+// Stack
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the value the system panicked with if it is an error, or nil otherwise
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// PanicHandler is called when a system panics, with the recovered panic as a SystemError wrapping a
+// PanicError. If it returns true the system is quarantined, which disables it like ErrorDisable.
+// The handler can be called from multiple goroutines at once
+type PanicHandler func(*SystemError) bool
@@ -0,0 +1,205 @@
+package ecs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var errTest = errors.New("test error")
+
+func TestSystemError(t *testing.T) {
+	a := assert.New(t)
+	err := error(&SystemError{
+		SystemID:    1,
+		EntityID:    2,
+		HasEntity:   true,
+		EventTypeID: EventType1,
+		Err:         errTest,
+	})
+	a.Equal("system 1 failed on entity 2 for event type int: test error", err.Error())
+	a.ErrorIs(err, errTest)
+
+	err = &SystemError{
+		SystemID:    1,
+		EventTypeID: EventType1,
+		Err:         errTest,
+	}
+	a.Equal("system 1 failed for event type int: test error", err.Error())
+}
+
+func TestECS_ErrorPolicy(t *testing.T) {
+	for _, test := range []struct {
+		policy ErrorPolicy
+		// The number of times the failing system should be called in each run
+		failingCalls []int
+		// Whether the system after the failing one should run
+		nextRuns bool
+	}{
+		{ErrorContinue, []int{3, 3}, true},
+		{ErrorStop, []int{1, 1}, false},
+		{ErrorDisable, []int{1, 0}, true},
+	} {
+		t.Run(map[ErrorPolicy]string{
+			ErrorContinue: "continue",
+			ErrorStop:     "stop",
+			ErrorDisable:  "disable",
+		}[test.policy], func(t *testing.T) {
+			a := assert.New(t)
+			ecs := New(WithErrorPolicy(test.policy))
+			a.Equal(test.policy, ecs.ErrorPolicy())
+
+			for i := 0; i < 3; i++ {
+				_, err := newComponent1(ecs, ecs.NewEntity("entity"))
+				a.NoError(err)
+			}
+
+			failingCalls := 0
+			failing := ecs.NewSystemErr(func(*ECS, Event, Entity) error {
+				failingCalls++
+				return errTest
+			}, EventType1, []ComponentTypeID{componentType1})
+			a.Nil(ecs.GetSystem(failing).Func())
+			a.NotNil(ecs.GetSystem(failing).ErrFunc())
+
+			nextRan := false
+			ecs.NewSystem(func(*ECS, Event, Entity) {
+				nextRan = true
+			}, EventType1, []ComponentTypeID{componentType1})
+
+			for _, calls := range test.failingCalls {
+				failingCalls = 0
+				newEvent1(ecs)
+				err := ecs.Run()
+				a.Equal(calls, failingCalls)
+				if calls > 0 {
+					a.ErrorIs(err, errTest)
+					var systemErr *SystemError
+					a.ErrorAs(err, &systemErr)
+					a.Equal(failing, systemErr.SystemID)
+					a.Equal(EventType1, systemErr.EventTypeID)
+					a.True(systemErr.HasEntity)
+					a.True(ecs.IsAlive(systemErr.EntityID))
+				} else {
+					a.NoError(err)
+				}
+			}
+			a.Equal(test.nextRuns, nextRan)
+		})
+	}
+}
+
+func TestECS_ErrorStopRequeuesEvents(t *testing.T) {
+	a := assert.New(t)
+	ecs := New(WithErrorPolicy(ErrorStop))
+
+	_, err := newComponent1(ecs, ecs.NewEntity("entity"))
+	a.NoError(err)
+
+	handled := make([]int, 0)
+	ecs.NewSystemErr(func(_ *ECS, event Event, _ Entity) error {
+		handled = append(handled, event.Data.(int))
+		if event.Data.(int) == 1 {
+			return errTest
+		}
+		return nil
+	}, EventType1, []ComponentTypeID{componentType1})
+
+	ecs.NewEvent(EventType1, 1)
+	ecs.NewEvent(EventType1, 2)
+	a.ErrorIs(ecs.Run(), errTest)
+	a.Equal([]int{1}, handled)
+
+	// The event after the failing one should be handled by the next run
+	a.NoError(ecs.Run())
+	a.Equal([]int{1, 2}, handled)
+}
+
+func TestSystemManager_RunSystemsParallelErrors(t *testing.T) {
+	a := assert.New(t)
+	ecs := &ECS{
+		EntityComponentManager: NewEntityComponentManager(),
+	}
+	m := newSystemManager(ecs)
+	m.SetErrorPolicy(ErrorStop)
+
+	_, err := newComponent1(ecs, ecs.NewEntity("entity"))
+	a.NoError(err)
+
+	m.NewSystemErr(func(*ECS, Event, Entity) error {
+		return errTest
+	}, EventType1, []ComponentTypeID{componentType1})
+	nextRan := false
+	m.NewSystem(func(*ECS, Event, Entity) {
+		nextRan = true
+	}, EventType1, []ComponentTypeID{componentType1})
+
+	// The systems haven't declared their access, so the second should be in a later batch
+	a.ErrorIs(m.RunSystemsParallel(Event{EventTypeID: EventType1}), errTest)
+	a.False(nextRan)
+}
+
+func TestPanicError(t *testing.T) {
+	a := assert.New(t)
+	err := error(&PanicError{
+		Value: errTest,
+		Stack: []byte("stack"),
+	})
+	a.Equal("panic: test error", err.Error())
+	a.ErrorIs(err, errTest)
+	a.Nil((&PanicError{Value: "test"}).Unwrap())
+}
+
+func TestSystemManager_PanicRecovery(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		t.Run(map[bool]string{false: "serial", true: "parallel"}[parallel], func(t *testing.T) {
+			a := assert.New(t)
+			ecs := &ECS{
+				EntityComponentManager: NewEntityComponentManager(),
+			}
+			m := newSystemManager(ecs)
+			run := m.RunSystems
+			if parallel {
+				run = m.RunSystemsParallel
+			}
+
+			entityID := ecs.NewEntity("entity")
+			_, err := newComponent1(ecs, entityID)
+			a.NoError(err)
+
+			panicking := m.NewSystem(func(*ECS, Event, Entity) {
+				panic("test panic")
+			}, EventType1, []ComponentTypeID{componentType1})
+			nextRan := false
+			m.NewSystem(func(*ECS, Event, Entity) {
+				nextRan = true
+			}, EventType1, []ComponentTypeID{componentType1})
+
+			// Without a handler the panic should be returned, and the system should keep running
+			for i := 0; i < 2; i++ {
+				err = run(Event{EventTypeID: EventType1})
+				var systemErr *SystemError
+				a.ErrorAs(err, &systemErr)
+				a.Equal(panicking, systemErr.SystemID)
+				a.True(systemErr.HasEntity)
+				a.Equal(entityID, systemErr.EntityID)
+				var panicErr *PanicError
+				a.ErrorAs(err, &panicErr)
+				a.Equal("test panic", panicErr.Value)
+				a.Contains(string(panicErr.Stack), "TestSystemManager_PanicRecovery")
+				a.True(nextRan)
+			}
+
+			// The handler should be able to quarantine the system
+			handled := make([]*SystemError, 0)
+			m.SetPanicHandler(func(err *SystemError) bool {
+				handled = append(handled, err)
+				return true
+			})
+			a.Error(run(Event{EventTypeID: EventType1}))
+			a.NoError(run(Event{EventTypeID: EventType1}))
+			a.Len(handled, 1)
+			a.Equal(panicking, handled[0].SystemID)
+		})
+	}
+}
@@ -2,6 +2,8 @@ package ecs
 
 import (
 	"reflect"
+	"sort"
+	"sync"
 )
 
 // EventTypeID is an identifier for an event type
@@ -11,9 +13,12 @@ type EventTypeID reflect.Type
 type Event struct {
 	EventTypeID
 	Data interface{}
+	// The entities the event is for, or nil if it is for every entity. An empty slice is for no
+	// entities. Systems only run on the targets they act on
+	Targets []EntityID
 }
 
-// EventManager manages all the events
+// EventManager manages all the events. It is safe to use from multiple goroutines
 type EventManager interface {
 	// NewEvent creates a new event of the given type. For efficiency, this function doesn't
 	// actually check that the given event is of the correct type
@@ -24,24 +29,99 @@ type EventManager interface {
 	//  NewEvent(reflect.TypeOf(data), data interface{})
 	NewEventReflect(data interface{})
 
-	// ForEvents calls the given iterator function on each event, in order. If the iterator returns
-	// false or an  error, the function will stop iterating (like a for loop break) and return the
-	// result of the iterator. Otherwise returns true, nil
+	// NewEventFor creates a new event of the given type that targets the given entities, so
+	// systems only run on those entities instead of every entity they act on. If no entities are
+	// given the event is for every entity, like one created with NewEvent. Handlers created with
+	// Subscribe are still called once
+	NewEventFor(EventTypeID, interface{}, ...EntityID)
+
+	// ForEvents calls the given iterator function on each event, in order (see SetEventPriority).
+	// If the iterator returns false or an  error, the function will stop iterating (like a for loop
+	// break) and return the result of the iterator. Otherwise returns true, nil. Events created by
+	// the iterator are queued, but not iterated over
 	ForEvents(func(Event) (bool, error)) (bool, error)
 
-	// ClearEvents clears the events in the event manager (but not the event types)
+	// TakeEvents removes all the events from the event manager and returns them, in order (see
+	// SetEventPriority). Events created afterwards are queued separately, so they can be handled
+	// later
+	TakeEvents() []Event
+
+	// RequeueEvents puts the given events back in the queue, in order before the events that are
+	// already queued, for example events taken with TakeEvents that weren't handled
+	RequeueEvents([]Event)
+
+	// ClearEvents clears the events in the event manager (but not the event types or timers)
 	ClearEvents()
+
+	// NewEventAfter creates a timer that creates an event of the given type after the delay, which
+	// targets the given entities the same as NewEventFor. The timer can be cancelled with
+	// CancelTimer before it fires
+	NewEventAfter(Delay, EventTypeID, interface{}, ...EntityID) TimerID
+
+	// NewEventEvery creates a timer that creates an event of the given type every time the delay
+	// passes, until it is cancelled with CancelTimer. The event targets the entities the same as
+	// NewEventAfter. The timer fires at most once per AdvanceTimers, and catches up on missed
+	// events in later calls
+	NewEventEvery(Delay, EventTypeID, interface{}, ...EntityID) TimerID
+
+	// CancelTimer stops the timer, so it doesn't create any more events. Returns false if the timer
+	// doesn't exist, for example because it has already fired
+	CancelTimer(TimerID) bool
+
+	// AdvanceTimers advances the tick by one, and creates the events for the timers that are due.
+	// The engine calls this at the start of each Run
+	AdvanceTimers()
+
+	// SetEventPriority sets the priority of the event type. Events with a higher priority are
+	// handled first, whatever order they were created in, and events with the same priority are
+	// handled in the order they were created. Event types have a priority of 0 by default
+	SetEventPriority(EventTypeID, int)
+
+	// SetEventCoalescer makes events of the given type be combined with the queued event of the
+	// same type using the given function, so at most one is queued at a time. A nil function
+	// removes the coalescer
+	SetEventCoalescer(EventTypeID, Coalescer)
+}
+
+// Coalescer combines a queued event with a new event of the same type, returning the event to keep
+// queued
+type Coalescer func(queued Event, event Event) Event
+
+// KeepLatest is a Coalescer that only keeps the latest event
+func KeepLatest(_ Event, event Event) Event {
+	return event
+}
+
+// queuedEvent is an event and the order it was queued in
+type queuedEvent struct {
+	Event
+	seq uint64
 }
 
 type eventManager struct {
+	lock       sync.Mutex
 	eventTypes map[reflect.Type]EventTypeID
-	eventQueue []Event
+	// The events of each type, in the order they were created
+	queues     map[EventTypeID][]queuedEvent
+	nextSeq    uint64
+	priorities map[EventTypeID]int
+	coalescers map[EventTypeID]Coalescer
+
+	clock Clock
+	// The number of times AdvanceTimers has been called
+	tick        uint64
+	timers      []*timer
+	nextTimerID TimerID
 }
 
 func newEventManager() *eventManager {
 	return &eventManager{
 		eventTypes: make(map[reflect.Type]EventTypeID),
-		eventQueue: make([]Event, 0),
+		queues:     make(map[EventTypeID][]queuedEvent),
+		priorities: make(map[EventTypeID]int),
+		coalescers: make(map[EventTypeID]Coalescer),
+		clock:      SystemClock(),
+		timers:     make([]*timer, 0),
 	}
 }
 
@@ -50,11 +130,56 @@ func NewEventManager() EventManager {
 	return newEventManager()
 }
 
+// NewEventManagerWithClock creates and returns a event manager, whose timers use the given clock
+func NewEventManagerWithClock(clock Clock) EventManager {
+	m := newEventManager()
+	m.clock = clock
+	return m
+}
+
+// Adds the event to the queue for its type, coalescing it if needed. The lock must be held
+func (m *eventManager) push(event Event) {
+	queue := m.queues[event.EventTypeID]
+	coalesce, ok := m.coalescers[event.EventTypeID]
+	if ok && len(queue) > 0 {
+		queue[len(queue)-1].Event = coalesce(queue[len(queue)-1].Event, event)
+		return
+	}
+	m.queues[event.EventTypeID] = append(queue, queuedEvent{
+		Event: event,
+		seq:   m.nextSeq,
+	})
+	m.nextSeq++
+}
+
+// Returns the queued events in the order they should be handled. The lock must be held
+func (m *eventManager) ordered() []Event {
+	queued := make([]queuedEvent, 0)
+	for _, queue := range m.queues {
+		queued = append(queued, queue...)
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		pi, pj := m.priorities[queued[i].EventTypeID], m.priorities[queued[j].EventTypeID]
+		if pi != pj {
+			return pi > pj
+		}
+		return queued[i].seq < queued[j].seq
+	})
+	events := make([]Event, len(queued))
+	for i, e := range queued {
+		events[i] = e.Event
+	}
+	return events
+}
+
 func (m *eventManager) NewEvent(eType EventTypeID, data interface{}) {
 	// We don't actually check that eType is valid, it's not actually important
 
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	// Add the event to the queue
-	m.eventQueue = append(m.eventQueue, Event{
+	m.push(Event{
 		EventTypeID: eType,
 		Data:        data,
 	})
@@ -64,8 +189,33 @@ func (m *eventManager) NewEventReflect(data interface{}) {
 	m.NewEvent(reflect.TypeOf(data), data)
 }
 
+func (m *eventManager) NewEventFor(eType EventTypeID, data interface{}, targets ...EntityID) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.push(Event{
+		EventTypeID: eType,
+		Data:        data,
+		Targets:     copyTargets(targets),
+	})
+}
+
+// Returns a copy of the targets of an event, or nil if there aren't any so the event is for every
+// entity
+func copyTargets(targets []EntityID) []EntityID {
+	if len(targets) == 0 {
+		return nil
+	}
+	return append(make([]EntityID, 0, len(targets)), targets...)
+}
+
 func (m *eventManager) ForEvents(i func(Event) (bool, error)) (bool, error) {
-	for _, event := range m.eventQueue {
+	// Iterate over a copy, so the iterator can create events
+	m.lock.Lock()
+	events := m.ordered()
+	m.lock.Unlock()
+
+	for _, event := range events {
 		ok, err := i(event)
 		if !ok || err != nil {
 			return ok, err
@@ -74,8 +224,54 @@ func (m *eventManager) ForEvents(i func(Event) (bool, error)) (bool, error) {
 	return true, nil
 }
 
+func (m *eventManager) TakeEvents() []Event {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	events := m.ordered()
+	m.queues = make(map[EventTypeID][]queuedEvent, len(m.queues))
+	return events
+}
+
+func (m *eventManager) RequeueEvents(events []Event) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// Queue the events again after the requeued ones, in the order they were queued in
+	queued := make([]queuedEvent, 0)
+	for _, queue := range m.queues {
+		queued = append(queued, queue...)
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		return queued[i].seq < queued[j].seq
+	})
+	m.queues = make(map[EventTypeID][]queuedEvent, len(m.queues))
+	m.nextSeq = 0
+	for _, event := range events {
+		m.push(event)
+	}
+	for _, event := range queued {
+		m.push(event.Event)
+	}
+}
+
 func (m *eventManager) ClearEvents() {
-	if len(m.eventQueue) > 0 {
-		m.eventQueue = m.eventQueue[:0]
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	clear(m.queues)
+}
+
+func (m *eventManager) SetEventPriority(eType EventTypeID, priority int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.priorities[eType] = priority
+}
+
+func (m *eventManager) SetEventCoalescer(eType EventTypeID, coalesce Coalescer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if coalesce == nil {
+		delete(m.coalescers, eType)
+	} else {
+		m.coalescers[eType] = coalesce
 	}
 }
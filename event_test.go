@@ -3,6 +3,7 @@ package ecs
 import (
 	"github.com/stretchr/testify/assert"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -23,7 +24,7 @@ func TestEventManager_NewEvent(t *testing.T) {
 	a.Equal([]Event{{
 		EventTypeID: EventType1,
 		Data:        Event1Value,
-	}}, m.eventQueue)
+	}}, m.ordered())
 }
 
 func TestEventManager_ClearEvents(t *testing.T) {
@@ -35,5 +36,152 @@ func TestEventManager_ClearEvents(t *testing.T) {
 	m.NewEventReflect(10)
 
 	m.ClearEvents()
-	a.Len(m.eventQueue, 0)
+	a.Len(m.ordered(), 0)
+}
+
+func TestEventManager_ForEvents(t *testing.T) {
+	a := assert.New(t)
+	m := newEventManager()
+
+	newEvent1(m)
+
+	// Events created while iterating should be queued, but not iterated over
+	count := 0
+	ok, err := m.ForEvents(func(event Event) (bool, error) {
+		count++
+		newEvent1(m)
+		return true, nil
+	})
+	a.True(ok)
+	a.NoError(err)
+	a.Equal(1, count)
+	a.Len(m.ordered(), 2)
+}
+
+func TestEventManager_TakeEvents(t *testing.T) {
+	a := assert.New(t)
+	m := newEventManager()
+
+	newEvent1(m)
+	m.NewEventReflect(10)
+
+	a.Equal([]Event{
+		{EventTypeID: EventType1, Data: Event1Value},
+		{EventTypeID: EventType1, Data: 10},
+	}, m.TakeEvents())
+	a.Len(m.ordered(), 0)
+
+	// Events created afterwards shouldn't change the taken events
+	events := m.TakeEvents()
+	newEvent1(m)
+	a.Len(events, 0)
+	a.Len(m.ordered(), 1)
+}
+
+func TestEventManager_RequeueEvents(t *testing.T) {
+	a := assert.New(t)
+	m := newEventManager()
+
+	m.NewEvent(EventType1, 1)
+	m.NewEvent(EventType1, 2)
+	events := m.TakeEvents()
+	m.NewEvent(EventType1, 3)
+
+	// The requeued events should come before the events queued since they were taken
+	m.RequeueEvents(events)
+	a.Equal([]Event{
+		{EventTypeID: EventType1, Data: 1},
+		{EventTypeID: EventType1, Data: 2},
+		{EventTypeID: EventType1, Data: 3},
+	}, m.TakeEvents())
+}
+
+func TestEventManager_Concurrent(t *testing.T) {
+	a := assert.New(t)
+	m := newEventManager()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				newEvent1(m)
+			}
+		}()
+	}
+	wg.Wait()
+
+	a.Len(m.TakeEvents(), 1000)
+}
+
+func TestEventManager_NewEventFor(t *testing.T) {
+	a := assert.New(t)
+	m := newEventManager()
+
+	targets := []EntityID{1, 2}
+	m.NewEventFor(EventType1, Event1Value, targets...)
+	targets[0] = 3
+	a.Equal([]Event{{
+		EventTypeID: EventType1,
+		Data:        Event1Value,
+		Targets:     []EntityID{1, 2},
+	}}, m.TakeEvents())
+
+	// Without targets the event should be for every entity, the same as the timers
+	m.NewEventFor(EventType1, Event1Value)
+	a.Equal([]Event{{EventTypeID: EventType1, Data: Event1Value}}, m.TakeEvents())
+}
+
+func TestEventManager_SetEventPriority(t *testing.T) {
+	a := assert.New(t)
+	m := newEventManager()
+
+	m.SetEventPriority(EventType2, 1)
+	m.SetEventPriority(EventType3, -1)
+	m.NewEvent(EventType3, true)
+	m.NewEvent(EventType1, 1)
+	m.NewEvent(EventType2, "a")
+	m.NewEvent(EventType1, 2)
+	m.NewEvent(EventType2, "b")
+
+	// Higher priorities should be first, and otherwise the events should be in order
+	a.Equal([]Event{
+		{EventTypeID: EventType2, Data: "a"},
+		{EventTypeID: EventType2, Data: "b"},
+		{EventTypeID: EventType1, Data: 1},
+		{EventTypeID: EventType1, Data: 2},
+		{EventTypeID: EventType3, Data: true},
+	}, m.TakeEvents())
+}
+
+func TestEventManager_SetEventCoalescer(t *testing.T) {
+	a := assert.New(t)
+	m := newEventManager()
+
+	m.SetEventCoalescer(EventType1, func(queued Event, event Event) Event {
+		queued.Data = queued.Data.(int) + event.Data.(int)
+		return queued
+	})
+	m.SetEventCoalescer(EventType2, KeepLatest)
+	m.NewEvent(EventType1, 1)
+	m.NewEvent(EventType2, "a")
+	m.NewEvent(EventType1, 2)
+	m.NewEvent(EventType2, "b")
+	m.NewEvent(EventType1, 3)
+
+	// The coalesced events should keep the position of the first event
+	a.Equal([]Event{
+		{EventTypeID: EventType1, Data: 6},
+		{EventTypeID: EventType2, Data: "b"},
+	}, m.TakeEvents())
+
+	// Events should only be coalesced with queued events
+	m.NewEvent(EventType2, "c")
+	a.Equal([]Event{{EventTypeID: EventType2, Data: "c"}}, m.TakeEvents())
+
+	m.SetEventCoalescer(EventType2, nil)
+	m.NewEvent(EventType2, "d")
+	m.NewEvent(EventType2, "e")
+	a.Len(m.TakeEvents(), 2)
 }
@@ -2,22 +2,38 @@ package main
 
 import (
 	_ "embed"
+	"flag"
 	"github.com/bhollier/ecs"
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/pixelgl"
 	"github.com/faiface/pixel/text"
 	"image"
 	"image/color"
-	"math"
+	"os"
 	"pong"
 	"pong/font"
 	"time"
 )
 
-const SecondsBetweenTicks = 0.01
+const TimeBetweenTicks = 10 * time.Millisecond
+
+var record = flag.String("record", "", "the file to record the events to, so they can be replayed")
 
 func main() {
-	engine := ecs.New()
+	flag.Parse()
+
+	options := make([]ecs.Option, 0)
+	var recorder *ecs.Recorder
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		recorder = ecs.NewRecorder(f)
+		options = append(options, ecs.WithRecorder(recorder))
+	}
+	engine := ecs.New(options...)
 
 	paddleSize := pixel.V(pong.PaddleWidth, pong.PaddleHeight)
 
@@ -105,9 +121,22 @@ func main() {
 	pong.AddInputSystem(engine)
 	pong.AddAISystem(engine)
 	pong.AddCollisionSystem(engine)
+	pong.AddServeSystem(engine)
 	pong.AddMoveSystem(engine)
-	pong.AddRenderSystem(engine)
-	pong.AddScoreRenderSystem(engine)
+	render := pong.AddRenderSystem(engine)
+	scoreRender := pong.AddScoreRenderSystem(engine)
+
+	// Draw the score on top of everything else
+	err = engine.SystemAfter(scoreRender, render)
+	if err != nil {
+		panic(err)
+	}
+
+	// Handle the input before updating, and update before rendering. Only the latest input is
+	// needed, as the input system reads the window's state
+	engine.SetEventPriority(pong.InputEventType, 2)
+	engine.SetEventPriority(pong.UpdateEventType, 1)
+	engine.SetEventCoalescer(pong.InputEventType, ecs.KeepLatest)
 
 	pixelgl.Run(func() {
 		window, err := pixelgl.NewWindow(pixelgl.WindowConfig{
@@ -126,20 +155,24 @@ func main() {
 			engine.World["sprite"] = pixel.NewSprite(pic, pic.Bounds())
 		}
 
+		// Update the engine at a fixed rate, and render as often as possible
+		timestep := ecs.NewTimestep(TimeBetweenTicks, func(step time.Duration) ecs.Event {
+			return ecs.Event{
+				EventTypeID: pong.UpdateEventType,
+				Data:        pong.UpdateEvent{DT: step.Seconds()},
+			}
+		}, func(alpha float64) ecs.Event {
+			return ecs.Event{
+				EventTypeID: pong.RenderEventType,
+				Data:        pong.RenderEvent{Alpha: alpha},
+			}
+		})
+
 		// Repeat until the window closes
-		prev := time.Now()
-		lag := 0.0
 		for !window.Closed() {
-			now := time.Now()
-			dt := now.Sub(prev)
-			prev = now
-			lag += dt.Seconds()
-			// Cap the lag to 1 second
-			math.Min(lag, 1)
-
 			// Update input
 			window.UpdateInput()
-			engine.NewEvent(pong.InputEventType, pong.InputEvent{})
+			engine.NewEvent(pong.InputEventType, pong.NewInputEvent(window))
 
 			if window.Pressed(pixelgl.KeyLeftControl) && window.JustPressed(pixelgl.KeyD) {
 				go func() {
@@ -151,23 +184,26 @@ func main() {
 				}()
 			}
 
-			// If enough time has passed
-			for lag >= SecondsBetweenTicks {
-				// Update the engine
-				engine.NewEvent(pong.UpdateEventType, pong.UpdateEvent{DT: SecondsBetweenTicks})
-
-				lag -= SecondsBetweenTicks
-			}
-
 			// Clear the window
 			window.Clear(color.Black)
-			engine.NewEvent(pong.RenderEventType, pong.RenderEvent{})
+			timestep.Tick(engine)
 
 			// Run the engine
-			engine.Run()
+			err := engine.Run()
+			if err != nil {
+				panic(err)
+			}
 
 			// Swap the buffers
 			window.SwapBuffers()
 		}
+
+		// Record the final state, so replays can check they match
+		if recorder != nil {
+			err := recorder.Finish(engine)
+			if err != nil {
+				panic(err)
+			}
+		}
 	})
 }
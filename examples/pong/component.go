@@ -4,48 +4,47 @@ import (
 	"github.com/bhollier/ecs"
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/text"
-	"reflect"
 )
 
 type PositionComponent struct {
 	pixel.Vec
 }
 
-var PositionComponentType = ecs.ComponentTypeID(reflect.TypeOf((*PositionComponent)(nil)).Elem())
+var PositionComponentType = ecs.ComponentType[PositionComponent]()
 
 type VelocityComponent struct {
 	pixel.Vec
 }
 
-var VelocityComponentType = ecs.ComponentTypeID(reflect.TypeOf((*VelocityComponent)(nil)).Elem())
+var VelocityComponentType = ecs.ComponentType[VelocityComponent]()
 
 type SizeComponent struct {
 	pixel.Vec
 }
 
-var SizeComponentType = ecs.ComponentTypeID(reflect.TypeOf((*SizeComponent)(nil)).Elem())
+var SizeComponentType = ecs.ComponentType[SizeComponent]()
 
 type PlayerComponent struct{}
 
-var PlayerComponentType = ecs.ComponentTypeID(reflect.TypeOf((*PlayerComponent)(nil)).Elem())
+var PlayerComponentType = ecs.ComponentType[PlayerComponent]()
 
 type AIComponent struct{}
 
-var AIComponentType = ecs.ComponentTypeID(reflect.TypeOf((*AIComponent)(nil)).Elem())
+var AIComponentType = ecs.ComponentType[AIComponent]()
 
 type BallComponent struct{}
 
-var BallComponentType = ecs.ComponentTypeID(reflect.TypeOf((*BallComponent)(nil)).Elem())
+var BallComponentType = ecs.ComponentType[BallComponent]()
 
 type ScorerComponent struct {
 	ScoreEntity ecs.EntityID
 }
 
-var ScorerComponentType = ecs.ComponentTypeID(reflect.TypeOf((*ScorerComponent)(nil)).Elem())
+var ScorerComponentType = ecs.ComponentType[ScorerComponent]()
 
 type ScoreComponent struct {
 	Score int
 	Text  *text.Text
 }
 
-var ScoreComponentType = ecs.ComponentTypeID(reflect.TypeOf((*ScoreComponent)(nil)).Elem())
+var ScoreComponentType = ecs.ComponentType[ScoreComponent]()
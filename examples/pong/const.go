@@ -1,5 +1,9 @@
 package pong
 
+import (
+	"time"
+)
+
 const ScreenWidth = 800.0
 const ScreenHeight = 600.0
 
@@ -9,3 +13,6 @@ const PaddleVelocity = 75
 
 const BallSize = 10
 const BallVelocity = 100
+
+// How long the ball waits in the middle of the screen after a point
+const ServeDelay = time.Second
@@ -2,19 +2,33 @@ package pong
 
 import (
 	"github.com/bhollier/ecs"
-	"reflect"
+	"github.com/faiface/pixel"
 )
 
 type UpdateEvent struct {
 	DT float64
 }
 
-var UpdateEventType = ecs.EventTypeID(reflect.TypeOf((*UpdateEvent)(nil)).Elem())
+var UpdateEventType = ecs.EventType[UpdateEvent]()
 
-type RenderEvent struct{}
+type RenderEvent struct {
+	// How far through the next update the time is, from 0 to 1
+	Alpha float64
+}
+
+var RenderEventType = ecs.EventType[RenderEvent]()
 
-var RenderEventType = ecs.EventTypeID(reflect.TypeOf((*RenderEvent)(nil)).Elem())
+// InputEvent is the state of the input, so it can be recorded
+type InputEvent struct {
+	Up   bool
+	Down bool
+}
 
-type InputEvent struct{}
+var InputEventType = ecs.EventType[InputEvent]()
+
+// ServeEvent starts the ball it targets moving again after a point
+type ServeEvent struct {
+	Velocity pixel.Vec
+}
 
-var InputEventType = ecs.EventTypeID(reflect.TypeOf((*InputEvent)(nil)).Elem())
+var ServeEventType = ecs.EventType[ServeEvent]()
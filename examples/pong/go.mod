@@ -1,6 +1,6 @@
 module pong
 
-go 1.16
+go 1.22
 
 require (
 	github.com/bhollier/ecs v0.0.0-20210726194131-1c535a4f0a2d
@@ -8,3 +8,14 @@ require (
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	golang.org/x/image v0.0.0-20210628002857-a66eb6448b8d
 )
+
+require (
+	github.com/faiface/glhf v0.0.0-20181018222622-82a6317ac380 // indirect
+	github.com/faiface/mainthread v0.0.0-20171120011319-8b78f0a41ae3 // indirect
+	github.com/go-gl/gl v0.0.0-20190320180904-bf2b1f2f34d7 // indirect
+	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20191125211704-12ad95a8df72 // indirect
+	github.com/go-gl/mathgl v0.0.0-20190416160123-c4601bc793c7 // indirect
+	github.com/pkg/errors v0.8.1 // indirect
+)
+
+replace github.com/bhollier/ecs => ../..
@@ -0,0 +1,77 @@
+package pong
+
+import (
+	"bytes"
+	"github.com/bhollier/ecs"
+	"github.com/bhollier/ecs/ecstest"
+	"github.com/faiface/pixel"
+	"testing"
+	"time"
+)
+
+// Creates an engine with the paddles, ball and walls, and the systems that don't need a window
+func newReplayEngine(t *testing.T, options ...ecs.Option) *ecs.ECS {
+	engine := ecs.New(options...)
+	paddleSize := pixel.V(PaddleWidth, PaddleHeight)
+
+	_, err := NewPaddle(engine, pixel.V(10, ScreenHeight/2), paddleSize, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewPaddle(engine, pixel.V(ScreenWidth-paddleSize.X, ScreenHeight/2), paddleSize, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewBall(engine, pixel.V(ScreenWidth/2, ScreenHeight/2), pixel.V(BallSize, BallSize),
+		pixel.V(-BallVelocity, -BallVelocity))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wall := range []struct {
+		name      string
+		pos, size pixel.Vec
+	}{
+		{"bottom wall", pixel.V(ScreenWidth/2, -(paddleSize.X / 2)),
+			pixel.V(ScreenWidth+(paddleSize.X*2), paddleSize.X)},
+		{"top wall", pixel.V(ScreenWidth/2, ScreenHeight+(paddleSize.X/2)),
+			pixel.V(ScreenWidth+(paddleSize.X*2), paddleSize.X)},
+	} {
+		_, err = NewHitbox(engine, wall.name, wall.pos, wall.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	AddInputSystem(engine)
+	AddAISystem(engine)
+	AddCollisionSystem(engine)
+	AddServeSystem(engine)
+	AddMoveSystem(engine)
+	return engine
+}
+
+func TestReplay(t *testing.T) {
+	buf := bytes.Buffer{}
+	clock := ecs.NewManualClock(time.Unix(0, 0))
+	recorder := ecs.NewRecorder(&buf)
+	engine := newReplayEngine(t, ecs.WithTimerClock(clock), ecs.WithRecorder(recorder))
+
+	// Move the player's paddle up and down
+	for i := 0; i < 200; i++ {
+		engine.NewEvent(InputEventType, InputEvent{Up: i%50 < 25, Down: i%50 >= 25})
+		engine.NewEvent(UpdateEventType, UpdateEvent{DT: 0.01})
+		err := engine.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(10 * time.Millisecond)
+	}
+	err := recorder.Finish(engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock = ecs.NewManualClock(time.Unix(0, 0))
+	ecstest.AssertReplay(t, newReplayEngine(t, ecs.WithTimerClock(clock)), &buf, clock,
+		InputEventType, UpdateEventType)
+}
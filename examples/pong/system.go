@@ -12,20 +12,26 @@ import (
 func MoveSystem(engine *ecs.ECS, update ecs.Event, entity ecs.Entity) {
 	dt := update.Data.(UpdateEvent).DT
 
-	posComp := entity.Get(PositionComponentType)
-	pos := posComp.Data.(PositionComponent)
-	vel := entity.Get(VelocityComponentType).Data.(VelocityComponent)
-
-	pos.Vec = pos.Add(vel.Scaled(dt))
+	vel := ecs.Get[VelocityComponent](entity)
 
-	engine.UpdateComponent(posComp.ID(), pos)
+	ecs.Mutate(entity, func(pos *PositionComponent) {
+		pos.Vec = pos.Add(vel.Scaled(dt))
+	})
 }
 
 func AddMoveSystem(engine *ecs.ECS) ecs.SystemID {
-	return engine.NewSystem(MoveSystem, UpdateEventType,
+	id := engine.NewSystem(MoveSystem, UpdateEventType,
 		[]ecs.ComponentTypeID{PositionComponentType, VelocityComponentType})
+	// Move the entities once their velocity is known. This can't fail, as the system isn't ordered
+	_ = engine.SetSystemStage(id, ecs.PostUpdate)
+	return id
 }
 
+// The entities that can be collided with. Balls pass through each other
+var collidableQuery = ecs.NewQuery().
+	With(PositionComponentType, SizeComponentType).
+	Without(BallComponentType)
+
 func CollisionSystem(engine *ecs.ECS, update ecs.Event, entity ecs.Entity) {
 	dt := update.Data.(UpdateEvent).DT
 
@@ -33,7 +39,7 @@ func CollisionSystem(engine *ecs.ECS, update ecs.Event, entity ecs.Entity) {
 	pos := posComp.Data.(PositionComponent)
 	velComp := entity.Get(VelocityComponentType)
 	vel := velComp.Data.(VelocityComponent)
-	size := entity.Get(SizeComponentType).Data.(SizeComponent)
+	size := ecs.Get[SizeComponent](entity)
 
 	// Predict where the entity will be
 	pos.Vec = pos.Add(vel.Scaled(dt))
@@ -42,23 +48,23 @@ func CollisionSystem(engine *ecs.ECS, update ecs.Event, entity ecs.Entity) {
 		pos.X+(size.X/2), pos.Y+(size.Y/2))
 
 	// Iterate over the other entities
-	others := engine.GetEntities([]ecs.ComponentTypeID{PositionComponentType, SizeComponentType})
+	others := engine.World["collidable"].(*ecs.CachedQuery).Entities()
 	for _, other := range others {
 		// If the entity isn't this one
 		if other.ID() != entity.ID() {
-			otherPos := other.Get(PositionComponentType).Data.(PositionComponent)
-			otherSize := other.Get(SizeComponentType).Data.(SizeComponent)
+			otherPos := ecs.Get[PositionComponent](other)
+			otherSize := ecs.Get[SizeComponent](other)
 			otherHitbox := pixel.R(otherPos.X-(otherSize.X/2), otherPos.Y-(otherSize.Y/2),
 				otherPos.X+(otherSize.X/2), otherPos.Y+(otherSize.Y/2))
 
 			// If the entities intersect
 			if hitbox.Intersects(otherHitbox) {
 				// If the entity is for scoring
-				scorerComp, ok := other.GetSafe(ScorerComponentType)
+				scorer, ok := ecs.GetSafe[ScorerComponent](other)
 				if ok {
 					// Add a point to the linked score entity
-					scoreEntity := scorerComp.Data.(ScorerComponent).ScoreEntity
-					scoreComp, ok := engine.GetEntity(scoreEntity).GetSafe(ScoreComponentType)
+					scoreComp, ok := engine.GetEntity(scorer.ScoreEntity).GetSafe(
+						ScoreComponentType)
 					if ok {
 						score := scoreComp.Data.(ScoreComponent)
 						score.Score++
@@ -70,6 +76,12 @@ func CollisionSystem(engine *ecs.ECS, update ecs.Event, entity ecs.Entity) {
 					pos.Vec = pixel.V(ScreenWidth/2, ScreenHeight/2)
 					engine.UpdateComponent(posComp.ID(), pos)
 
+					// Stop the ball until it is served
+					engine.NewEventAfter(ecs.Duration(ServeDelay), ServeEventType, ServeEvent{
+						Velocity: vel.Vec,
+					}, entity.ID())
+					vel.Vec = pixel.ZV
+
 				} else {
 					// The direction the ball bounces back depends on the size of the thing it hits.
 					// This only really works for pong
@@ -90,46 +102,66 @@ func CollisionSystem(engine *ecs.ECS, update ecs.Event, entity ecs.Entity) {
 }
 
 func AddCollisionSystem(engine *ecs.ECS) ecs.SystemID {
+	engine.World["collidable"] = engine.NewCachedQuery(collidableQuery)
 	return engine.NewSystem(CollisionSystem, UpdateEventType, []ecs.ComponentTypeID{
 		PositionComponentType, VelocityComponentType, SizeComponentType})
 }
 
-func InputSystem(engine *ecs.ECS, _ ecs.Event, entity ecs.Entity) {
-	velComp := entity.Get(VelocityComponentType)
-	vel := velComp.Data.(VelocityComponent)
+func ServeSystem(engine *ecs.ECS, event ecs.Event, entity ecs.Entity) {
+	serve := event.Data.(ServeEvent)
+	ecs.Mutate(entity, func(vel *VelocityComponent) {
+		vel.Vec = serve.Velocity
+	})
+}
 
-	window := engine.World["window"].(*pixelgl.Window)
+func AddServeSystem(engine *ecs.ECS) ecs.SystemID {
+	return engine.NewSystem(ServeSystem, ServeEventType, []ecs.ComponentTypeID{
+		BallComponentType, VelocityComponentType})
+}
 
-	if window.Pressed(pixelgl.KeyW) || window.Pressed(pixelgl.KeyUp) {
-		vel.Y = PaddleVelocity
-	} else if window.Pressed(pixelgl.KeyS) || window.Pressed(pixelgl.KeyDown) {
-		vel.Y = -PaddleVelocity
-	} else {
-		vel.Y = 0
-	}
+func InputSystem(engine *ecs.ECS, event ecs.Event, entity ecs.Entity) {
+	input := event.Data.(InputEvent)
 
-	engine.UpdateComponent(velComp.ID(), vel)
+	ecs.Mutate(entity, func(vel *VelocityComponent) {
+		if input.Up {
+			vel.Y = PaddleVelocity
+		} else if input.Down {
+			vel.Y = -PaddleVelocity
+		} else {
+			vel.Y = 0
+		}
+	})
+}
+
+// NewInputEvent returns an InputEvent with the state of the window's input
+func NewInputEvent(window *pixelgl.Window) InputEvent {
+	return InputEvent{
+		Up:   window.Pressed(pixelgl.KeyW) || window.Pressed(pixelgl.KeyUp),
+		Down: window.Pressed(pixelgl.KeyS) || window.Pressed(pixelgl.KeyDown),
+	}
 }
 
 func AddInputSystem(engine *ecs.ECS) ecs.SystemID {
-	return engine.NewSystem(InputSystem, InputEventType,
+	id := engine.NewSystem(InputSystem, InputEventType,
 		[]ecs.ComponentTypeID{PlayerComponentType, VelocityComponentType})
+	_ = engine.SetSystemStage(id, ecs.PreUpdate)
+	return id
 }
 
 func AISystem(engine *ecs.ECS, _ ecs.Event, entity ecs.Entity) {
-	pos := entity.Get(PositionComponentType).Data.(PositionComponent)
+	pos := ecs.Get[PositionComponent](entity)
 	velComp := entity.Get(VelocityComponentType)
 	vel := velComp.Data.(VelocityComponent)
 
 	// Find the ball
-	balls := engine.GetEntities([]ecs.ComponentTypeID{BallComponentType, PositionComponentType})
+	balls := engine.World["balls"].(*ecs.CachedQuery).Entities()
 	// If no ball could be found
 	if len(balls) == 0 {
 		fmt.Printf("warning: no ball found")
 		return
 	}
 	// Get the position of the first ball
-	ballPos := balls[0].Get(PositionComponentType).Data.(PositionComponent)
+	ballPos := ecs.Get[PositionComponent](balls[0])
 
 	// Don't sweat the small stuff
 	if math.Abs(ballPos.Y-pos.Y) < 10 {
@@ -148,13 +180,18 @@ func AISystem(engine *ecs.ECS, _ ecs.Event, entity ecs.Entity) {
 }
 
 func AddAISystem(engine *ecs.ECS) ecs.SystemID {
-	return engine.NewSystem(AISystem, UpdateEventType,
+	engine.World["balls"] = engine.NewCachedQuery(
+		ecs.NewQuery().With(BallComponentType, PositionComponentType))
+	id := engine.NewSystem(AISystem, UpdateEventType,
 		[]ecs.ComponentTypeID{AIComponentType, PositionComponentType, VelocityComponentType})
+	// Decide where the paddle goes before it collides with anything
+	_ = engine.SetSystemStage(id, ecs.PreUpdate)
+	return id
 }
 
 func RenderSystem(engine *ecs.ECS, _ ecs.Event, entity ecs.Entity) {
-	pos := entity.Get(PositionComponentType).Data.(PositionComponent)
-	size := entity.Get(SizeComponentType).Data.(SizeComponent)
+	pos := ecs.Get[PositionComponent](entity)
+	size := ecs.Get[SizeComponent](entity)
 
 	window := engine.World["window"].(*pixelgl.Window)
 	sprite := engine.World["sprite"].(*pixel.Sprite)
@@ -167,12 +204,14 @@ func RenderSystem(engine *ecs.ECS, _ ecs.Event, entity ecs.Entity) {
 }
 
 func AddRenderSystem(engine *ecs.ECS) ecs.SystemID {
-	return engine.NewSystem(RenderSystem, RenderEventType,
+	id := engine.NewSystem(RenderSystem, RenderEventType,
 		[]ecs.ComponentTypeID{PositionComponentType, SizeComponentType})
+	_ = engine.SetSystemStage(id, ecs.Render)
+	return id
 }
 
-func ScoreRenderSystem(engine *ecs.ECS, _ ecs.Event, entity ecs.Entity) {
-	score := entity.Get(ScoreComponentType).Data.(ScoreComponent)
+func ScoreRenderSystem(engine *ecs.ECS, _ ecs.Event, entity ecs.Entity) error {
+	score := ecs.Get[ScoreComponent](entity)
 
 	window := engine.World["window"].(*pixelgl.Window)
 
@@ -180,13 +219,16 @@ func ScoreRenderSystem(engine *ecs.ECS, _ ecs.Event, entity ecs.Entity) {
 	score.Text.Color = color.White
 	_, err := fmt.Fprintf(score.Text, "%d", score.Score)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	score.Text.Draw(window, pixel.IM)
+	return nil
 }
 
 func AddScoreRenderSystem(engine *ecs.ECS) ecs.SystemID {
-	return engine.NewSystem(ScoreRenderSystem, RenderEventType,
+	id := engine.NewSystemErr(ScoreRenderSystem, RenderEventType,
 		[]ecs.ComponentTypeID{ScoreComponentType})
+	_ = engine.SetSystemStage(id, ecs.Render)
+	return id
 }
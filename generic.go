@@ -0,0 +1,84 @@
+package ecs
+
+import (
+	"reflect"
+)
+
+// ComponentType returns the component type ID of T. Equivalent to:
+//
+//	ComponentTypeID(reflect.TypeOf((*T)(nil)).Elem())
+func ComponentType[T any]() ComponentTypeID {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// EventType returns the event type ID of T. Equivalent to:
+//
+//	EventTypeID(reflect.TypeOf((*T)(nil)).Elem())
+func EventType[T any]() EventTypeID {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Add creates a new component of type T in the given entity and returns its ID. Equivalent to:
+//
+//	m.NewComponent(eID, ComponentType[T](), data)
+func Add[T any](m EntityComponentManager, eID EntityID, data T) (ComponentID, error) {
+	return m.NewComponent(eID, ComponentType[T](), data)
+}
+
+// Has returns whether the entity has a component of type T
+func Has[T any](e Entity) bool {
+	return e.Has(ComponentType[T]())
+}
+
+// Get returns a copy of the entity's component of type T. If the entity doesn't have one, the zero
+// value of T is returned
+func Get[T any](e Entity) T {
+	data, _ := e.Get(ComponentType[T]()).Data.(T)
+	return data
+}
+
+// GetSafe returns a copy of the entity's component of type T, and whether the entity has one. Use
+// GetPtr or Mutate to modify the component in place
+func GetSafe[T any](e Entity) (T, bool) {
+	c, ok := e.GetSafe(ComponentType[T]())
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	data, ok := c.Data.(T)
+	return data, ok
+}
+
+// GetPtr calls f with a pointer to the entity's component of type T in storage. Components are
+// stored as interface values, so the pointer can't outlive the lock on the storage: it is only
+// valid until f returns, and what it points to is written back to storage before the lock is
+// released. f must not keep the pointer or use the ECS. Returns false if the entity doesn't have a
+// component of type T, in which case f isn't called. Equivalent to Mutate
+func GetPtr[T any](e Entity, f func(*T)) bool {
+	return Mutate(e, f)
+}
+
+// Mutate calls f with a pointer to the entity's component of type T, so it can be modified in
+// place. The lock on the component's storage is held until f returns, so f must not use the ECS.
+// Returns false if the entity doesn't have a component of type T
+func Mutate[T any](e Entity, f func(*T)) bool {
+	called := false
+	ok := e.UpdateFunc(ComponentType[T](), func(ptr *interface{}) {
+		data, ok := (*ptr).(T)
+		if !ok {
+			return
+		}
+		f(&data)
+		*ptr = data
+		called = true
+	})
+	return ok && called
+}
+
+// Remove deletes the entity's component of type T. If the entity doesn't have one this is a no-op
+func Remove[T any](m EntityComponentManager, eID EntityID) {
+	c, ok := m.GetEntity(eID).GetSafe(ComponentType[T]())
+	if ok {
+		m.DeleteComponent(c.ID())
+	}
+}
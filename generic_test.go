@@ -0,0 +1,63 @@
+package ecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type genericComponent struct {
+	Value int
+}
+
+func TestComponentType(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(componentType1, ComponentType[int]())
+	a.Equal(EventType1, EventType[int]())
+	a.Equal(transformComponentType, ComponentType[transformComponent]())
+}
+
+func TestGeneric(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		entityID := m.NewEntity("entity")
+
+		id, err := Add(m, entityID, genericComponent{Value: 1})
+		a.NoError(err)
+		a.Equal(ComponentType[genericComponent](), id.ComponentTypeID)
+
+		entity := m.GetEntity(entityID)
+		a.True(Has[genericComponent](entity))
+		a.False(Has[int](entity))
+
+		a.Equal(genericComponent{Value: 1}, Get[genericComponent](entity))
+		a.Equal(0, Get[int](entity))
+
+		c, ok := GetSafe[genericComponent](entity)
+		a.True(ok)
+		a.Equal(genericComponent{Value: 1}, c)
+		_, ok = GetSafe[int](entity)
+		a.False(ok)
+
+		// Modifying the component in place
+		a.True(GetPtr(entity, func(c *genericComponent) {
+			c.Value = 2
+		}))
+		a.Equal(genericComponent{Value: 2}, Get[genericComponent](entity))
+		a.False(GetPtr(entity, func(*int) {
+			a.Fail("function shouldn't be called")
+		}))
+		a.True(Mutate(entity, func(c *genericComponent) {
+			c.Value = 3
+		}))
+		a.Equal(genericComponent{Value: 3}, Get[genericComponent](entity))
+		a.False(Mutate(entity, func(*int) {
+			a.Fail("mutate function shouldn't be called")
+		}))
+
+		// Removing a component the entity doesn't have is a no-op
+		Remove[int](m, entityID)
+		a.True(Has[genericComponent](entity))
+
+		Remove[genericComponent](m, entityID)
+		a.False(Has[genericComponent](entity))
+	})
+}
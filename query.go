@@ -0,0 +1,283 @@
+package ecs
+
+import (
+	"sync"
+)
+
+// Query selects entities by the component types they have. Queries are built by chaining clauses
+// onto NewQuery, for example:
+//
+//	NewQuery().With(positionType, sizeType).Without(ballType)
+//
+// Every clause returns a new query, so a query can be extended without changing the original
+type Query struct {
+	with     []ComponentTypeID
+	without  []ComponentTypeID
+	optional []ComponentTypeID
+	anyOf    [][]ComponentTypeID
+
+	// The change filters, which are relative to the since tick
+	added   []ComponentTypeID
+	changed []ComponentTypeID
+	removed []ComponentTypeID
+	since   uint64
+}
+
+// NewQuery creates an empty query, which matches every entity
+func NewQuery() Query {
+	return Query{}
+}
+
+// Returns a copy of s with the given types appended, so queries never share a backing array
+func appendTypes(s []ComponentTypeID, types []ComponentTypeID) []ComponentTypeID {
+	result := make([]ComponentTypeID, 0, len(s)+len(types))
+	result = append(result, s...)
+	return append(result, types...)
+}
+
+// With returns a query that only matches entities with all the given component types
+func (q Query) With(types ...ComponentTypeID) Query {
+	q.with = appendTypes(q.with, types)
+	return q
+}
+
+// Without returns a query that only matches entities with none of the given component types
+func (q Query) Without(types ...ComponentTypeID) Query {
+	q.without = appendTypes(q.without, types)
+	return q
+}
+
+// Optional returns a query that may use the given component types, but doesn't require the
+// entities to have them. Optional types don't change which entities the query matches
+func (q Query) Optional(types ...ComponentTypeID) Query {
+	q.optional = appendTypes(q.optional, types)
+	return q
+}
+
+// AnyOf returns a query that only matches entities with at least one of the given component
+// types. Calling AnyOf more than once requires each set to be matched
+func (q Query) AnyOf(types ...ComponentTypeID) Query {
+	anyOf := make([][]ComponentTypeID, 0, len(q.anyOf)+1)
+	anyOf = append(anyOf, q.anyOf...)
+	q.anyOf = append(anyOf, appendTypes(nil, types))
+	return q
+}
+
+// Added returns a query that only matches entities with all the given component types, where each
+// component was created after the query's change tick (see Since)
+func (q Query) Added(types ...ComponentTypeID) Query {
+	q.added = appendTypes(q.added, types)
+	return q
+}
+
+// Changed returns a query that only matches entities with all the given component types, where each
+// component was created or updated after the query's change tick (see Since)
+func (q Query) Changed(types ...ComponentTypeID) Query {
+	q.changed = appendTypes(q.changed, types)
+	return q
+}
+
+// Removed returns a query that only matches entities that had all the given component types removed
+// after the query's change tick (see Since). The entities don't need to still be alive to match,
+// but deleted entities aren't returned by the EntityComponentManager
+func (q Query) Removed(types ...ComponentTypeID) Query {
+	q.removed = appendTypes(q.removed, types)
+	return q
+}
+
+// Since returns a query with its change filters (Added, Changed and Removed) relative to the given
+// change tick. Systems ignore this, and instead use the tick they last ran
+func (q Query) Since(tick uint64) Query {
+	q.since = tick
+	return q
+}
+
+// Required returns the component types an entity must have to match the query (the With clause)
+func (q Query) Required() []ComponentTypeID {
+	return appendTypes(nil, q.with)
+}
+
+// Optionals returns the component types the query may use but doesn't require
+func (q Query) Optionals() []ComponentTypeID {
+	return appendTypes(nil, q.optional)
+}
+
+// Returns whether something with the given has function matches the query, ignoring the change
+// ticks. Added and Changed types are required
+func (q Query) matches(has func(ComponentTypeID) bool) bool {
+	for _, types := range [][]ComponentTypeID{q.with, q.added, q.changed} {
+		for _, cType := range types {
+			if !has(cType) {
+				return false
+			}
+		}
+	}
+	for _, cType := range q.without {
+		if has(cType) {
+			return false
+		}
+	}
+	for _, anyOf := range q.anyOf {
+		found := false
+		for _, cType := range anyOf {
+			if has(cType) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns a copy of the query without its change filters. The Added and Changed types are still
+// required
+func (q Query) withoutChangeFilters() Query {
+	q.with = appendTypes(appendTypes(q.with, q.added), q.changed)
+	q.added, q.changed, q.removed = nil, nil, nil
+	return q
+}
+
+// Returns whether the query has any change filters
+func (q Query) hasChangeFilters() bool {
+	return len(q.added) > 0 || len(q.changed) > 0 || len(q.removed) > 0
+}
+
+// Returns whether the entity's change ticks match the query's change filters, relative to the given
+// change tick
+func (q Query) matchesChanges(e Entity, since uint64) bool {
+	for _, cType := range q.added {
+		ticks, ok := e.Ticks(cType)
+		if !ok || ticks.Added <= since {
+			return false
+		}
+	}
+	for _, cType := range q.changed {
+		ticks, ok := e.Ticks(cType)
+		if !ok || ticks.Changed <= since {
+			return false
+		}
+	}
+	for _, cType := range q.removed {
+		tick, ok := e.RemovedTick(cType)
+		if !ok || tick <= since {
+			return false
+		}
+	}
+	return true
+}
+
+// Removes the entities that don't match the query's change filters
+func (q Query) filterChanges(entities []Entity) []Entity {
+	if !q.hasChangeFilters() {
+		return entities
+	}
+	filtered := entities[:0]
+	for _, e := range entities {
+		if q.matchesChanges(e, q.since) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// Matches returns whether the entity matches the query, including its change filters
+func (q Query) Matches(e Entity) bool {
+	return q.matches(e.Has) && (!q.hasChangeFilters() || q.matchesChanges(e, q.since))
+}
+
+// CachedQuery is a query whose matching entities are kept up to date as components are created and
+// deleted, so iterating over them doesn't require checking every entity. Cached queries are created
+// with SystemManager.NewCachedQuery and are safe to use from multiple goroutines. Len, Contains and
+// EntityIDs ignore the query's change filters, as checking them needs the entities' components
+type CachedQuery struct {
+	query    Query
+	ecs      *ECS
+	lock     sync.RWMutex
+	entities map[EntityID]struct{}
+	close    func()
+}
+
+// Query returns the query the cache is for
+func (q *CachedQuery) Query() Query {
+	return q.query
+}
+
+// Len returns the number of entities matching the query
+func (q *CachedQuery) Len() int {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	return len(q.entities)
+}
+
+// Contains returns whether the entity matches the query
+func (q *CachedQuery) Contains(id EntityID) bool {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	_, ok := q.entities[id]
+	return ok
+}
+
+// EntityIDs returns the IDs of the entities matching the query, in no particular order
+func (q *CachedQuery) EntityIDs() []EntityID {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	ids := make([]EntityID, 0, len(q.entities))
+	for id := range q.entities {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Entities returns the entities matching the query, in no particular order
+func (q *CachedQuery) Entities() []Entity {
+	ids := q.EntityIDs()
+	entities := make([]Entity, 0, len(ids))
+	for _, id := range ids {
+		entity, ok := q.ecs.GetEntitySafe(id)
+		if ok {
+			entities = append(entities, entity)
+		}
+	}
+	return q.query.filterChanges(entities)
+}
+
+// ForEntities calls the given iterator function on each entity matching the query. If the iterator
+// returns false or an error, the function will stop iterating (like a for loop break) and return
+// the result of the iterator. Otherwise returns true, nil
+func (q *CachedQuery) ForEntities(i func(Entity) (bool, error)) (bool, error) {
+	for _, id := range q.EntityIDs() {
+		entity, ok := q.ecs.GetEntitySafe(id)
+		if !ok || q.query.hasChangeFilters() && !q.query.matchesChanges(entity, q.query.since) {
+			continue
+		}
+		ok, err := i(entity)
+		if !ok || err != nil {
+			return ok, err
+		}
+	}
+	return true, nil
+}
+
+// Close stops the cache from being updated. The entities matching the query when it was closed can
+// still be iterated over
+func (q *CachedQuery) Close() {
+	if q.close != nil {
+		q.close()
+	}
+}
+
+// Adds or removes the entity from the cache, depending on whether it matches the query. The change
+// filters are checked when the cache is iterated over instead
+func (q *CachedQuery) update(entity Entity) {
+	matches := q.query.matches(entity.Has)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if matches {
+		q.entities[entity.ID()] = struct{}{}
+	} else {
+		delete(q.entities, entity.ID())
+	}
+}
@@ -0,0 +1,183 @@
+package ecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	a := assert.New(t)
+
+	q := NewQuery().With(componentType1)
+	with2 := q.With(componentType2)
+	without2 := q.Without(componentType2)
+
+	// Adding a clause shouldn't change the original query
+	a.Equal([]ComponentTypeID{componentType1}, q.Required())
+	a.Equal([]ComponentTypeID{componentType1, componentType2}, with2.Required())
+	a.Equal([]ComponentTypeID{componentType1}, without2.Required())
+
+	optional := q.Optional(componentType3)
+	a.Equal([]ComponentTypeID{componentType3}, optional.Optionals())
+	a.Empty(q.Optionals())
+}
+
+func TestQuery_Matches(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		entityID1 := m.NewEntity("entity")
+		_, err := newComponent1(m, entityID1)
+		a.NoError(err)
+		_, err = newComponent2(m, entityID1)
+		a.NoError(err)
+
+		entityID2 := m.NewEntity("entity")
+		_, err = newComponent1(m, entityID2)
+		a.NoError(err)
+		_, err = newComponent3(m, entityID2)
+		a.NoError(err)
+
+		entityID3 := m.NewEntity("entity")
+		_, err = newComponent3(m, entityID3)
+		a.NoError(err)
+
+		all := []EntityID{entityID1, entityID2, entityID3}
+
+		for _, test := range []struct {
+			name     string
+			query    Query
+			expected []EntityID
+		}{
+			{"empty", NewQuery(), all},
+			{"with", NewQuery().With(componentType1), []EntityID{entityID1, entityID2}},
+			{"without", NewQuery().Without(componentType2), []EntityID{entityID2, entityID3}},
+			{"with and without", NewQuery().With(componentType1).Without(componentType2),
+				[]EntityID{entityID2}},
+			{"optional", NewQuery().With(componentType3).Optional(componentType1),
+				[]EntityID{entityID2, entityID3}},
+			{"any of", NewQuery().AnyOf(componentType2, componentType3), all},
+			{"any of twice", NewQuery().AnyOf(componentType1).AnyOf(componentType2, componentType3),
+				[]EntityID{entityID1, entityID2}},
+			{"no match", NewQuery().With(componentType2).Without(componentType1), []EntityID{}},
+		} {
+			a.ElementsMatch(test.expected, m.QueryEntityIDs(test.query), test.name)
+
+			entities := m.QueryEntities(test.query)
+			ids := make([]EntityID, 0, len(entities))
+			for _, e := range entities {
+				a.True(test.query.Matches(e), test.name)
+				ids = append(ids, e.ID())
+			}
+			a.ElementsMatch(test.expected, ids, test.name)
+		}
+	})
+}
+
+func TestCachedQuery(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		ecs := &ECS{
+			EntityComponentManager: m,
+		}
+		sm := newSystemManager(ecs)
+
+		entityID1 := ecs.NewEntity("entity")
+		_, err := newComponent1(ecs, entityID1)
+		a.NoError(err)
+
+		// The cache should be filled with the existing entities
+		q := sm.NewCachedQuery(NewQuery().With(componentType1).Without(componentType2))
+		a.Equal(1, q.Len())
+		a.True(q.Contains(entityID1))
+
+		// New entities should be added
+		entityID2 := ecs.NewEntity("entity")
+		_, err = newComponent1(ecs, entityID2)
+		a.NoError(err)
+		a.ElementsMatch([]EntityID{entityID1, entityID2}, q.EntityIDs())
+
+		// And entities that stop matching should be removed
+		id, err := newComponent2(ecs, entityID1)
+		a.NoError(err)
+		a.Equal([]EntityID{entityID2}, q.EntityIDs())
+
+		ecs.DeleteComponent(id)
+		a.ElementsMatch([]EntityID{entityID1, entityID2}, q.EntityIDs())
+
+		ecs.DeleteEntity(entityID2)
+		a.False(q.Contains(entityID2))
+
+		entities := q.Entities()
+		a.Len(entities, 1)
+		a.Equal(entityID1, entities[0].ID())
+
+		count := 0
+		ok, err := q.ForEntities(func(e Entity) (bool, error) {
+			a.Equal(entityID1, e.ID())
+			count++
+			return true, nil
+		})
+		a.True(ok)
+		a.NoError(err)
+		a.Equal(1, count)
+
+		// A closed cache shouldn't be updated
+		q.Close()
+		a.Len(sm.queries, 0)
+		_, err = newComponent1(ecs, ecs.NewEntity("entity"))
+		a.NoError(err)
+		a.Equal([]EntityID{entityID1}, q.EntityIDs())
+	})
+}
+
+func TestQuery_ChangeFilters(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, m EntityComponentManager) {
+		entityID1 := m.NewEntity("entity")
+		id1, err := newComponent1(m, entityID1)
+		a.NoError(err)
+
+		entityID2 := m.NewEntity("entity")
+		_, err = newComponent1(m, entityID2)
+		a.NoError(err)
+
+		entityID3 := m.NewEntity("entity")
+		_, err = newComponent1(m, entityID3)
+		a.NoError(err)
+		id3, err := newComponent3(m, entityID3)
+		a.NoError(err)
+
+		// Changes made after the tick is advanced are after the old tick
+		tick := m.ChangeTick()
+		m.AdvanceChangeTick()
+		m.UpdateComponent(id1, 2)
+		_, err = newComponent2(m, entityID2)
+		a.NoError(err)
+		m.DeleteComponent(id3)
+
+		for _, test := range []struct {
+			name     string
+			query    Query
+			expected []EntityID
+		}{
+			{"added before", NewQuery().Added(componentType1),
+				[]EntityID{entityID1, entityID2, entityID3}},
+			{"added", NewQuery().Added(componentType1).Since(tick), []EntityID{}},
+			{"added other", NewQuery().Added(componentType2).Since(tick), []EntityID{entityID2}},
+			{"changed", NewQuery().Changed(componentType1).Since(tick), []EntityID{entityID1}},
+			{"changed or added", NewQuery().Changed(componentType2).Since(tick),
+				[]EntityID{entityID2}},
+			{"removed", NewQuery().Removed(componentType3).Since(tick), []EntityID{entityID3}},
+			{"removed with", NewQuery().With(componentType2).Removed(componentType3).Since(tick),
+				[]EntityID{}},
+			{"after", NewQuery().Changed(componentType1).Since(tick + 1), []EntityID{}},
+		} {
+			a.ElementsMatch(test.expected, m.QueryEntityIDs(test.query), test.name)
+
+			entities := m.QueryEntities(test.query)
+			ids := make([]EntityID, 0, len(entities))
+			for _, e := range entities {
+				a.True(test.query.Matches(e), test.name)
+				ids = append(ids, e.ID())
+			}
+			a.ElementsMatch(test.expected, ids, test.name)
+		}
+	})
+}
@@ -0,0 +1,329 @@
+package ecs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// The kinds of record in a recording
+const (
+	recordEvent  = "event"
+	recordTimer  = "timer"
+	recordCancel = "cancel"
+	recordRun    = "run"
+	recordDump   = "dump"
+)
+
+// record is a line in a recording
+type record struct {
+	Kind string `json:"kind"`
+	// For events and timers
+	Type    string          `json:"type,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Targets *[]EntityID     `json:"targets,omitempty"`
+	// For timers, the delay in nanoseconds or in ticks, and whether the timer repeats
+	Delay  int64 `json:"delay,omitempty"`
+	Ticks  bool  `json:"ticks,omitempty"`
+	Repeat bool  `json:"repeat,omitempty"`
+	// For timers and cancelled timers, the ID of the timer
+	Timer *TimerID `json:"timer,omitempty"`
+	// For runs and timers, the time on the recorder's clock in nanoseconds
+	Time int64 `json:"time,omitempty"`
+	// For runs, whether the engine was run with RunParallel, and its event policy
+	Parallel  bool        `json:"parallel,omitempty"`
+	Policy    EventPolicy `json:"policy,omitempty"`
+	MaxPasses int         `json:"max_passes,omitempty"`
+	// For dumps, the state of the engine from DumpJSON
+	Dump json.RawMessage `json:"dump,omitempty"`
+}
+
+// Recorder records the events created with an engine to a writer as JSON, with a mark at each Run,
+// so they can be replayed with Replay. Events created by systems aren't recorded, as the systems
+// create them again when replayed, but events created by anything else are, including from other
+// goroutines while Run is running. The same goes for timers, and for timers being cancelled. Create
+// one with NewRecorder, and pass it to New with WithRecorder
+type Recorder struct {
+	lock  sync.Mutex
+	enc   *json.Encoder
+	clock Clock
+	err   error
+}
+
+// NewRecorder creates and returns a recorder that writes to the given writer
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		enc:   json.NewEncoder(w),
+		clock: SystemClock(),
+	}
+}
+
+// WithRecorder makes the engine record its events with the given recorder. The recorder uses the
+// engine's timer clock (see WithTimerClock) to record when each Run happened and each timer was
+// created
+func WithRecorder(r *Recorder) Option {
+	return func(ecs *ECS) {
+		ecs.recorder = r
+	}
+}
+
+func (r *Recorder) write(rec record) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// Stop recording after an error, as the recording can't be replayed anyway
+	if r.err == nil {
+		r.err = r.enc.Encode(rec)
+	}
+}
+
+// Returns a record of the given kind for the event. Returns false if the event's data can't be
+// recorded, after which nothing else is recorded
+func (r *Recorder) eventRecord(kind string, event Event) (record, bool) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		r.lock.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.lock.Unlock()
+		return record{}, false
+	}
+	rec := record{
+		Kind: kind,
+		Type: event.EventTypeID.String(),
+		Data: data,
+	}
+	if event.Targets != nil {
+		targets := append([]EntityID(nil), event.Targets...)
+		rec.Targets = &targets
+	}
+	return rec, true
+}
+
+func (r *Recorder) event(event Event) {
+	rec, ok := r.eventRecord(recordEvent, event)
+	if ok {
+		r.write(rec)
+	}
+}
+
+func (r *Recorder) timer(id TimerID, delay Delay, repeat bool, event Event) {
+	rec, ok := r.eventRecord(recordTimer, event)
+	if !ok {
+		return
+	}
+	rec.Delay = int64(delay.duration)
+	if delay.inTicks {
+		rec.Delay, rec.Ticks = int64(delay.ticks), true
+	}
+	rec.Repeat = repeat
+	rec.Timer = &id
+	rec.Time = r.clock.Now().UnixNano()
+	r.write(rec)
+}
+
+func (r *Recorder) cancel(id TimerID) {
+	r.write(record{
+		Kind:  recordCancel,
+		Timer: &id,
+	})
+}
+
+// Marks the start of a Run, with how the engine is run
+func (r *Recorder) startRun(parallel bool, policy EventPolicy, maxPasses int) {
+	r.write(record{
+		Kind:      recordRun,
+		Time:      r.clock.Now().UnixNano(),
+		Parallel:  parallel,
+		Policy:    policy,
+		MaxPasses: maxPasses,
+	})
+}
+
+// Finish records the engine's state using DumpJSON, so a replay can check it ends in the same state.
+// Returns the first error from recording
+func (r *Recorder) Finish(ecs *ECS) error {
+	dump, err := ecs.DumpJSON()
+	if err != nil {
+		return err
+	}
+	r.write(record{
+		Kind: recordDump,
+		Dump: json.RawMessage(dump),
+	})
+	return r.Err()
+}
+
+// Err returns the first error from recording, after which nothing else is recorded
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// recordingEventManager is an EventManager that records the events created with it
+type recordingEventManager struct {
+	EventManager
+	recorder *Recorder
+}
+
+func (m recordingEventManager) NewEvent(eType EventTypeID, data interface{}) {
+	m.recorder.event(Event{
+		EventTypeID: eType,
+		Data:        data,
+	})
+	m.EventManager.NewEvent(eType, data)
+}
+
+func (m recordingEventManager) NewEventReflect(data interface{}) {
+	m.NewEvent(reflect.TypeOf(data), data)
+}
+
+func (m recordingEventManager) NewEventFor(eType EventTypeID, data interface{},
+	targets ...EntityID) {
+	m.recorder.event(Event{
+		EventTypeID: eType,
+		Data:        data,
+		Targets:     copyTargets(targets),
+	})
+	m.EventManager.NewEventFor(eType, data, targets...)
+}
+
+func (m recordingEventManager) NewEventAfter(delay Delay, eType EventTypeID, data interface{},
+	targets ...EntityID) TimerID {
+	id := m.EventManager.NewEventAfter(delay, eType, data, targets...)
+	m.recorder.timer(id, delay, false, Event{
+		EventTypeID: eType,
+		Data:        data,
+		Targets:     copyTargets(targets),
+	})
+	return id
+}
+
+func (m recordingEventManager) NewEventEvery(delay Delay, eType EventTypeID, data interface{},
+	targets ...EntityID) TimerID {
+	id := m.EventManager.NewEventEvery(delay, eType, data, targets...)
+	m.recorder.timer(id, delay, true, Event{
+		EventTypeID: eType,
+		Data:        data,
+		Targets:     copyTargets(targets),
+	})
+	return id
+}
+
+func (m recordingEventManager) CancelTimer(id TimerID) bool {
+	m.recorder.cancel(id)
+	return m.EventManager.CancelTimer(id)
+}
+
+// Decodes the event in the record, whose type has to be one of the given types
+func decodeEvent(rec record, types map[string]EventTypeID) (Event, error) {
+	eType, ok := types[rec.Type]
+	if !ok {
+		return Event{}, fmt.Errorf("event type %s wasn't given", rec.Type)
+	}
+	data := reflect.New(eType)
+	err := json.Unmarshal(rec.Data, data.Interface())
+	if err != nil {
+		return Event{}, err
+	}
+	event := Event{
+		EventTypeID: eType,
+		Data:        data.Elem().Interface(),
+	}
+	if rec.Targets != nil {
+		event.Targets = *rec.Targets
+	}
+	return event, nil
+}
+
+// Replay creates the events and timers recorded by a Recorder, and runs the engine at each Run in
+// the recording, with Run or RunParallel and the event policy it was recorded with. An engine set up
+// the same way as the recorded one should end in the same state. The event types in the recording
+// have to be given, so their data can be decoded. If clock isn't nil it is set to the recorded time
+// before each Run and timer, and should be the engine's timer clock. Returns the state the recording
+// was finished with (see Recorder.Finish), or an empty string if it wasn't, along with any errors
+// from running the engine
+func Replay(ecs *ECS, r io.Reader, clock *ManualClock, eventTypes ...EventTypeID) (string, error) {
+	types := make(map[string]EventTypeID, len(eventTypes))
+	for _, eType := range eventTypes {
+		types[eType.String()] = eType
+	}
+
+	errs := make([]error, 0)
+	dump := ""
+	// The IDs of the replayed timers, by the IDs they were recorded with
+	timers := make(map[TimerID]TimerID)
+	dec := json.NewDecoder(r)
+	for {
+		var rec record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return dump, err
+		}
+
+		switch rec.Kind {
+		case recordEvent:
+			event, err := decodeEvent(rec, types)
+			if err != nil {
+				return dump, err
+			}
+			if event.Targets != nil {
+				ecs.NewEventFor(event.EventTypeID, event.Data, event.Targets...)
+			} else {
+				ecs.NewEvent(event.EventTypeID, event.Data)
+			}
+		case recordTimer:
+			event, err := decodeEvent(rec, types)
+			if err != nil {
+				return dump, err
+			}
+			if rec.Timer == nil {
+				return dump, errors.New("timer record has no ID")
+			}
+			if clock != nil {
+				clock.Set(time.Unix(0, rec.Time))
+			}
+			delay := Duration(time.Duration(rec.Delay))
+			if rec.Ticks {
+				delay = Ticks(uint64(rec.Delay))
+			}
+			newTimer := ecs.NewEventAfter
+			if rec.Repeat {
+				newTimer = ecs.NewEventEvery
+			}
+			timers[*rec.Timer] = newTimer(delay, event.EventTypeID, event.Data, event.Targets...)
+		case recordCancel:
+			if rec.Timer == nil {
+				return dump, errors.New("cancel record has no timer ID")
+			}
+			// Timers created by systems weren't recorded, but are created again with the same IDs
+			id, ok := timers[*rec.Timer]
+			if !ok {
+				id = *rec.Timer
+			}
+			ecs.CancelTimer(id)
+		case recordRun:
+			if clock != nil {
+				clock.Set(time.Unix(0, rec.Time))
+			}
+			ecs.eventPolicy = rec.Policy
+			if rec.MaxPasses > 0 {
+				ecs.maxEventPasses = rec.MaxPasses
+			}
+			errs = append(errs, ecs.run(rec.Parallel))
+		case recordDump:
+			dump = string(rec.Dump)
+		default:
+			return dump, fmt.Errorf("unknown record kind %s", rec.Kind)
+		}
+	}
+	return dump, errors.Join(errs...)
+}
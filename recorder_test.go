@@ -0,0 +1,193 @@
+package ecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Creates an engine with an entity, and a system that adds the event data to the entity's
+// component. The system also creates a timer, and an event that shouldn't be recorded
+func newRecorderTestEngine(clock Clock, options ...Option) *ECS {
+	ecs := New(append(options, WithTimerClock(clock))...)
+	_, _ = newComponent1(ecs, ecs.NewEntity("entity"))
+	ecs.NewSystem(func(ecs *ECS, event Event, entity Entity) {
+		Mutate(entity, func(data *int) {
+			*data += event.Data.(int)
+		})
+		if event.Data.(int) == 2 {
+			ecs.NewEventAfter(Duration(time.Second), EventType1, 10)
+			ecs.NewEvent(EventType1, 100)
+		}
+	}, EventType1, []ComponentTypeID{componentType1})
+	return ecs
+}
+
+func TestRecorder(t *testing.T) {
+	a := assert.New(t)
+	buf := bytes.Buffer{}
+	clock := NewManualClock(time.Unix(0, 1))
+	recorder := NewRecorder(&buf)
+	ecs := newRecorderTestEngine(clock, WithRecorder(recorder))
+
+	// Events created outside the systems while Run is running, for example by an input goroutine,
+	// should be recorded
+	ecs.Subscribe(EventType1, func(_ *ECS, event Event) error {
+		if event.Data.(int) == 1 {
+			done := make(chan struct{})
+			go func() {
+				ecs.NewEvent(EventType1, 1000)
+				close(done)
+			}()
+			<-done
+		}
+		return nil
+	})
+
+	ecs.NewEvent(EventType1, 1)
+	ecs.NewEventFor(EventType1, 2, 0)
+	// Timers created outside the systems should be recorded, and so should cancelling them
+	ecs.NewEventAfter(Duration(time.Second), EventType1, 5)
+	ecs.CancelTimer(ecs.NewEventEvery(Ticks(1), EventType1, 10000))
+	a.NoError(ecs.Run())
+	clock.Advance(time.Second)
+	a.NoError(ecs.RunParallel())
+	a.NoError(recorder.Finish(ecs))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	a.Len(lines, 9)
+	a.Equal(`{"kind":"event","type":"int","data":1}`, lines[0])
+	a.Equal(`{"kind":"event","type":"int","data":2,"targets":[0]}`, lines[1])
+	a.Equal(`{"kind":"timer","type":"int","data":5,"delay":1000000000,"timer":0,"time":1}`,
+		lines[2])
+	a.Equal(`{"kind":"timer","type":"int","data":10000,"delay":1,"ticks":true,"repeat":true,`+
+		`"timer":1,"time":1}`, lines[3])
+	a.Equal(`{"kind":"cancel","timer":1}`, lines[4])
+	a.Equal(`{"kind":"run","time":1,"max_passes":16}`, lines[5])
+	a.Equal(`{"kind":"event","type":"int","data":1000}`, lines[6])
+	a.Equal(`{"kind":"run","time":1000000001,"parallel":true,"max_passes":16}`, lines[7])
+
+	// Replaying on an engine set up the same way should end in the same state
+	clock = NewManualClock(time.Unix(0, 0))
+	replay := newRecorderTestEngine(clock)
+	dump, err := Replay(replay, &buf, clock, EventType1)
+	a.NoError(err)
+	expected, err := ecs.DumpJSON()
+	a.NoError(err)
+	a.Equal(withoutTimestamp(a, expected), withoutTimestamp(a, dump))
+	a.Equal(component1Value+1118, Get[int](replay.GetEntity(0)))
+}
+
+// Returns the JSON dump without its timestamp, as dumps made at different times can't be compared
+func withoutTimestamp(a *assert.Assertions, dump string) map[string]interface{} {
+	var v map[string]interface{}
+	a.NoError(json.Unmarshal([]byte(dump), &v))
+	delete(v, "timestamp")
+	return v
+}
+
+// Creates an engine with entities that a system despawns and respawns, so the new entities reuse
+// the indices of the deleted ones
+func newRespawnTestEngine(m EntityComponentManager, clock Clock, options ...Option) *ECS {
+	ecs := New(append(options, WithEntityComponentManager(m), WithTimerClock(clock))...)
+	for i := 0; i < 20; i++ {
+		_, _ = ecs.NewComponent(ecs.NewEntity("entity"), componentType1, i)
+	}
+	ecs.NewSystem(func(ecs *ECS, event Event, entity Entity) {
+		value := Get[int](entity)
+		if value%event.Data.(int) == 0 {
+			ecs.DeleteEntity(entity.ID())
+			ecs.Commands().Spawn("respawned", map[ComponentTypeID]interface{}{
+				componentType1: value + 1,
+				componentType2: float64(value),
+			})
+		}
+	}, EventType1, []ComponentTypeID{componentType1})
+	ecs.Subscribe(EventType1, func(ecs *ECS, _ Event) error {
+		ecs.DeleteEmptyEntities()
+		return nil
+	})
+	return ecs
+}
+
+func TestReplay_Respawn(t *testing.T) {
+	for _, impl := range entityComponentManagers {
+		t.Run(impl.name, func(t *testing.T) {
+			a := assert.New(t)
+			buf := bytes.Buffer{}
+			clock := NewManualClock(time.Unix(0, 0))
+			recorder := NewRecorder(&buf)
+			ecs := newRespawnTestEngine(impl.new(), clock, WithRecorder(recorder))
+
+			for _, n := range []int{2, 3, 2, 5} {
+				ecs.NewEvent(EventType1, n)
+				a.NoError(ecs.Run())
+			}
+			a.NoError(recorder.Finish(ecs))
+			recording := buf.String()
+
+			// Every replay should reuse the indices in the same order as the recording
+			for i := 0; i < 5; i++ {
+				clock := NewManualClock(time.Unix(0, 0))
+				replay := newRespawnTestEngine(impl.new(), clock)
+				dump, err := Replay(replay, strings.NewReader(recording), clock, EventType1)
+				a.NoError(err)
+				a.Equal(withoutTimestamp(a, dump), withoutTimestamp(a, mustDumpJSON(a, replay)))
+			}
+		})
+	}
+}
+
+// Returns the JSON dump of the engine
+func mustDumpJSON(a *assert.Assertions, ecs *ECS) string {
+	dump, err := ecs.DumpJSON()
+	a.NoError(err)
+	return dump
+}
+
+func TestReplay_RunOptions(t *testing.T) {
+	a := assert.New(t)
+	buf := bytes.Buffer{}
+	clock := NewManualClock(time.Unix(0, 0))
+
+	// Creates an engine whose system counts the events and creates the next one, up to 3
+	newEngine := func(options ...Option) (*ECS, *[]int) {
+		ecs := New(append(options, WithTimerClock(clock))...)
+		_, _ = newComponent1(ecs, ecs.NewEntity("entity"))
+		handled := make([]int, 0)
+		ecs.NewSystem(func(ecs *ECS, event Event, _ Entity) {
+			n := event.Data.(int)
+			handled = append(handled, n)
+			if n < 3 {
+				ecs.NewEvent(EventType1, n+1)
+			}
+		}, EventType1, []ComponentTypeID{componentType1})
+		return ecs, &handled
+	}
+
+	ecs, _ := newEngine(WithEventPolicy(EventsDrain), WithRecorder(NewRecorder(&buf)))
+	ecs.NewEvent(EventType1, 1)
+	a.NoError(ecs.RunParallel())
+
+	// The replay should drain the events like the recorded engine, even though it wasn't created
+	// with the policy
+	replay, handled := newEngine()
+	_, err := Replay(replay, &buf, clock, EventType1)
+	a.NoError(err)
+	a.Equal([]int{1, 2, 3}, *handled)
+}
+
+func TestReplay_Errors(t *testing.T) {
+	a := assert.New(t)
+	ecs := New()
+
+	_, err := Replay(ecs, strings.NewReader(`{"kind":"event","type":"int","data":1}`), nil)
+	a.Error(err)
+	_, err = Replay(ecs, strings.NewReader(`{"kind":"other"}`), nil)
+	a.Error(err)
+	_, err = Replay(ecs, strings.NewReader(`{`), nil)
+	a.Error(err)
+}
@@ -0,0 +1,103 @@
+package ecs
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Stage is a group of systems that run together. Every system in a stage runs before any system in
+// a later stage. Systems are in the Update stage unless they're moved with SetSystemStage. Stages
+// are ordered by their value, so other stages can be added between the predefined ones
+type Stage int
+
+const (
+	// PreUpdate is for systems that prepare for the update, like reading input
+	PreUpdate Stage = iota * 100
+	// Update is the default stage
+	Update
+	// PostUpdate is for systems that act on the results of the update, like moving entities
+	PostUpdate
+	// Render is for systems that draw the entities
+	Render
+)
+
+// String returns the name of the stage
+func (s Stage) String() string {
+	switch s {
+	case PreUpdate:
+		return "PreUpdate"
+	case Update:
+		return "Update"
+	case PostUpdate:
+		return "PostUpdate"
+	case Render:
+		return "Render"
+	default:
+		return fmt.Sprintf("Stage(%d)", int(s))
+	}
+}
+
+// Resolves the order the given systems run in. Returns the systems in batches, where each system in
+// a batch only has to run after the systems in earlier batches. Within a batch systems are in
+// registration order. Returns an error if the Before constraints can't be satisfied
+func resolveSchedule(systems []system) ([][]SystemID, error) {
+	// Group the systems by stage
+	stages := make(map[Stage][]SystemID)
+	for id, s := range systems {
+		if s.removed {
+			continue
+		}
+		stages[s.stage] = append(stages[s.stage], SystemID(id))
+	}
+	order := make([]Stage, 0, len(stages))
+	for stage := range stages {
+		order = append(order, stage)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return order[i] < order[j]
+	})
+
+	// Count the systems each system has to run after
+	inDegree := make([]int, len(systems))
+	for id, s := range systems {
+		for _, other := range s.before {
+			if systems[other].stage < s.stage {
+				return nil, fmt.Errorf("system %d can't run before system %d, "+
+					"as it is in a later stage (%s, %s)", id, other, s.stage, systems[other].stage)
+			}
+			if systems[other].stage == s.stage {
+				inDegree[other]++
+			}
+		}
+	}
+
+	schedule := make([][]SystemID, 0)
+	for _, stage := range order {
+		remaining := stages[stage]
+		for len(remaining) > 0 {
+			// Every system that doesn't have to wait for another can run in this batch
+			batch := make([]SystemID, 0)
+			waiting := make([]SystemID, 0)
+			for _, id := range remaining {
+				if inDegree[id] == 0 {
+					batch = append(batch, id)
+				} else {
+					waiting = append(waiting, id)
+				}
+			}
+			if len(batch) == 0 {
+				return nil, fmt.Errorf("systems %v have a cycle in their order", waiting)
+			}
+			for _, id := range batch {
+				for _, other := range systems[id].before {
+					if systems[other].stage == stage {
+						inDegree[other]--
+					}
+				}
+			}
+			schedule = append(schedule, batch)
+			remaining = waiting
+		}
+	}
+	return schedule, nil
+}
@@ -0,0 +1,90 @@
+package ecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func newScheduleTestSystems(n int) (*systemManager, []SystemID, *[]SystemID) {
+	ecs := &ECS{
+		EntityComponentManager: NewEntityComponentManager(),
+	}
+	m := newSystemManager(ecs)
+	_, _ = newComponent1(ecs, ecs.NewEntity("entity"))
+
+	// Record the order the systems run in
+	lock := sync.Mutex{}
+	ran := make([]SystemID, 0)
+	ids := make([]SystemID, n)
+	for i := range ids {
+		ids[i] = m.NewSystem(func(*ECS, Event, Entity) {
+			lock.Lock()
+			defer lock.Unlock()
+			ran = append(ran, ids[i])
+		}, EventType1, []ComponentTypeID{componentType1})
+	}
+	return m, ids, &ran
+}
+
+func TestSystemManager_Schedule(t *testing.T) {
+	a := assert.New(t)
+	m, ids, ran := newScheduleTestSystems(4)
+
+	// Systems should run in registration order by default
+	a.Equal(ids, m.Schedule())
+	m.RunSystems(Event{EventTypeID: EventType1})
+	a.Equal(ids, *ran)
+
+	a.NoError(m.SetSystemStage(ids[0], Render))
+	a.NoError(m.SetSystemStage(ids[3], PreUpdate))
+	a.Equal(Render, m.GetSystem(ids[0]).Stage())
+	a.NoError(m.SystemAfter(ids[1], ids[2]))
+	a.Equal([]SystemID{ids[1]}, m.GetSystem(ids[2]).Before())
+	a.Equal([]SystemID{ids[3], ids[2], ids[1], ids[0]}, m.Schedule())
+
+	*ran = (*ran)[:0]
+	m.RunSystems(Event{EventTypeID: EventType1})
+	a.Equal(m.Schedule(), *ran)
+}
+
+func TestSystemManager_ScheduleErrors(t *testing.T) {
+	a := assert.New(t)
+	m, ids, _ := newScheduleTestSystems(3)
+
+	a.NoError(m.SystemBefore(ids[0], ids[1]))
+	a.NoError(m.SystemBefore(ids[1], ids[2]))
+	a.Error(m.SystemBefore(ids[0], ids[0]))
+
+	// A cycle should be rejected, and not change the schedule
+	a.Error(m.SystemBefore(ids[2], ids[0]))
+	a.Empty(m.GetSystem(ids[2]).Before())
+	a.Equal(ids, m.Schedule())
+
+	// A system can't run before a system in an earlier stage
+	a.Error(m.SetSystemStage(ids[0], PostUpdate))
+	a.Equal(Update, m.GetSystem(ids[0]).Stage())
+	a.NoError(m.SetSystemStage(ids[2], PostUpdate))
+	a.NoError(m.SetSystemStage(ids[0], PreUpdate))
+	a.Error(m.SystemAfter(ids[0], ids[2]))
+}
+
+func TestSystemManager_RunSystemsParallelSchedule(t *testing.T) {
+	a := assert.New(t)
+	m, ids, ran := newScheduleTestSystems(4)
+
+	a.NoError(m.SystemBefore(ids[3], ids[0]))
+	a.NoError(m.SetSystemStage(ids[2], Render))
+	a.Equal([][]SystemID{{ids[1], ids[3]}, {ids[0]}, {ids[2]}}, m.schedule)
+
+	m.RunSystemsParallel(Event{EventTypeID: EventType1})
+	a.ElementsMatch([]SystemID{ids[1], ids[3]}, (*ran)[:2])
+	a.Equal([]SystemID{ids[0], ids[2]}, (*ran)[2:])
+}
+
+func TestStage_String(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("PreUpdate", PreUpdate.String())
+	a.Equal("Render", Render.String())
+	a.Equal("Stage(150)", (Update + 50).String())
+}
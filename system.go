@@ -1,7 +1,13 @@
 package ecs
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 // SystemID is an identifier for a system
@@ -10,20 +16,274 @@ type SystemID int
 // SystemFunc is a type alias for a system function
 type SystemFunc func(*ECS, Event, Entity)
 
+// HandlerFunc is a function subscribed to an event type, which is called once for each event
+type HandlerFunc func(*ECS, Event) error
+
+// BatchSystemFunc is a system function that is given all the entities the system operates on at
+// once, for example to compare entities with each other
+type BatchSystemFunc func(*ECS, Event, []Entity) error
+
 type system struct {
+	// Only one of f, errF, batchF and handler is set
 	f           SystemFunc
-	triggeredBy EventTypeID
-	actsOn      []ComponentTypeID
+	errF        SystemErrFunc
+	batchF      BatchSystemFunc
+	handler     HandlerFunc
+	triggeredBy Trigger
+	query       Query
 	entities    map[EntityID]struct{}
+	// The change tick the system last ran at
+	lastRun uint64
+	stage   Stage
+	// The systems this system has to run before
+	before []SystemID
+	access access
+	// The number of entities each goroutine is given at a time, or 0 to run on one goroutine
+	chunkSize int
+	// The conditions that all have to be true for the system to run
+	conditions []RunCondition
+	// Whether the system has been disabled, for example by ErrorDisable
+	disabled bool
+	// Whether the system has been removed. Removed systems are kept so the IDs stay the same
+	removed bool
+}
+
+// runOptions is how a system is run
+type runOptions struct {
+	// If not nil, the system panics when it accesses a component type it didn't declare
+	checker *accessChecker
+	policy  ErrorPolicy
+	// If not nil, called when the system panics
+	onPanic PanicHandler
+	// The change tick the system runs at. Systems that run at the same time share a tick, which
+	// is safe as they can't write the types the others access
+	tick uint64
 }
 
-func (s *system) Run(ecs *ECS, event Event) {
-	// If the system is triggered by the event
-	if s.triggeredBy == event.EventTypeID {
-		for id := range s.entities {
-			s.f(ecs, event, ecs.GetEntity(id))
+// Runs the system against the event, and returns the errors it returned. Panics are recovered and
+// returned as a PanicError. With ErrorStop or ErrorDisable, the system stops at its first error
+func (s *system) Run(ecs *ECS, id SystemID, event Event, opts runOptions) error {
+	// If the system could be triggered by the event
+	if !s.triggeredBy.mayTrigger(event.EventTypeID) || s.disabled || s.removed {
+		return nil
+	}
+	// And is triggered by it, and its conditions are met. This calls the trigger's function and
+	// the conditions, so the error is a panic from one of them
+	run, err := s.shouldRun(ecs, event)
+	if !run && err == nil {
+		return nil
+	}
+
+	// Changes made while the system runs get its tick, so the system won't see its own changes
+	// next time. The tick is advanced again afterwards so it sees any changes made before it next
+	// runs
+	since := s.lastRun
+	if run {
+		s.lastRun = opts.tick
+	}
+
+	checker, policy := opts.checker, opts.policy
+	systemECS := ecs.systemECS()
+	if checker != nil {
+		systemECS = checker.ecs(systemECS)
+	}
+
+	lock := sync.Mutex{}
+	errs := make([]error, 0)
+	var failed, quarantined atomic.Bool
+
+	// Returns the entity if the system should run on it
+	getEntity := func(eID EntityID) (Entity, bool) {
+		// Skip entities that have been deleted
+		entity, ok := ecs.GetEntitySafe(eID)
+		if !ok {
+			return entity, false
+		}
+		// Skip entities that haven't changed since the system last ran
+		if s.query.hasChangeFilters() && !s.query.matchesChanges(entity, since) {
+			return entity, false
+		}
+		if checker != nil {
+			entity = checker.entity(entity)
+		}
+		return entity, true
+	}
+
+	// Records the error from running the system, on the entity if hasEntity is true. Returns false
+	// if the system should stop
+	handleErr := func(eID EntityID, hasEntity bool, err error) bool {
+		if err == nil {
+			return !quarantined.Load()
+		}
+		systemErr := &SystemError{
+			SystemID:    id,
+			EntityID:    eID,
+			HasEntity:   hasEntity,
+			EventTypeID: event.EventTypeID,
+			Err:         err,
+		}
+		lock.Lock()
+		errs = append(errs, systemErr)
+		lock.Unlock()
+		failed.Store(true)
+
+		// Let the handler decide whether a panicking system should keep running
+		var panicErr *PanicError
+		if opts.onPanic != nil && errors.As(err, &panicErr) && opts.onPanic(systemErr) {
+			quarantined.Store(true)
+		}
+		return policy == ErrorContinue && !quarantined.Load()
+	}
+
+	// Runs the system on the entity. Returns false if the system should stop
+	runEntity := func(eID EntityID) bool {
+		if failed.Load() && policy != ErrorContinue {
+			return false
+		}
+		entity, ok := getEntity(eID)
+		if !ok {
+			return true
+		}
+		return handleErr(eID, true, s.call(systemECS, event, entity))
+	}
+
+	if err != nil {
+		handleErr(0, false, err)
+	} else if s.handler != nil {
+		handleErr(0, false, s.callHandler(systemECS, event))
+	} else if s.batchF != nil {
+		// Batch systems run once on all their entities (or the targets), in the order of their IDs
+		var ids []EntityID
+		if event.Targets != nil {
+			ids = s.targets(event.Targets)
+			sort.Slice(ids, func(i, j int) bool {
+				return ids[i] < ids[j]
+			})
+		} else {
+			ids = sortedEntityIDs(s.entities)
+		}
+		entities := make([]Entity, 0, len(ids))
+		for _, eID := range ids {
+			entity, ok := getEntity(eID)
+			if ok {
+				entities = append(entities, entity)
+			}
 		}
+		handleErr(0, false, s.callBatch(systemECS, event, entities))
+	} else if event.Targets != nil {
+		for _, eID := range s.targets(event.Targets) {
+			if !runEntity(eID) {
+				break
+			}
+		}
+	} else if s.chunkSize <= 0 || len(s.entities) <= s.chunkSize {
+		// In the order of their IDs, so the system makes its changes in the same order every time
+		for _, eID := range sortedEntityIDs(s.entities) {
+			if !runEntity(eID) {
+				break
+			}
+		}
+	} else {
+		s.runChunks(runEntity)
+	}
+
+	if (failed.Load() && policy == ErrorDisable) || quarantined.Load() {
+		s.disabled = true
+	}
+	return errors.Join(errs...)
+}
+
+// Returns the given targets that the system acts on, without duplicates
+func (s *system) targets(targets []EntityID) []EntityID {
+	ids := make([]EntityID, 0, len(targets))
+	seen := make(map[EntityID]struct{}, len(targets))
+	for _, eID := range targets {
+		_, ok := s.entities[eID]
+		_, dup := seen[eID]
+		if ok && !dup {
+			ids = append(ids, eID)
+			seen[eID] = struct{}{}
+		}
+	}
+	return ids
+}
+
+// Splits the system's entities into chunks, and runs them on as many goroutines as can run at once
+func (s *system) runChunks(runEntity func(EntityID) bool) {
+	ids := sortedEntityIDs(s.entities)
+	chunks := (len(ids) + s.chunkSize - 1) / s.chunkSize
+	workers := min(runtime.GOMAXPROCS(0), chunks)
+
+	var next atomic.Int64
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			// Take chunks until there are none left
+			for {
+				chunk := int(next.Add(1)) - 1
+				if chunk >= chunks {
+					return
+				}
+				start := chunk * s.chunkSize
+				end := min(start+s.chunkSize, len(ids))
+				for _, id := range ids[start:end] {
+					if !runEntity(id) {
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Recovers from a panic, and sets err to it as a PanicError. Has to be deferred
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{
+			Value: r,
+			Stack: debug.Stack(),
+		}
+	}
+}
+
+// Returns whether the system is triggered by the event and its conditions are met, which are checked
+// in order until one is false. Recovers from any panic as a PanicError
+func (s *system) shouldRun(ecs *ECS, event Event) (run bool, err error) {
+	defer recoverPanic(&err)
+	if !s.triggeredBy.Triggers(event) {
+		return false, nil
+	}
+	for _, condition := range s.conditions {
+		if !condition(ecs, event) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Calls the system's function, recovering from any panic as a PanicError
+func (s *system) call(ecs *ECS, event Event, entity Entity) (err error) {
+	defer recoverPanic(&err)
+	if s.errF != nil {
+		return s.errF(ecs, event, entity)
 	}
+	s.f(ecs, event, entity)
+	return nil
+}
+
+// Calls the system's handler, recovering from any panic as a PanicError
+func (s *system) callHandler(ecs *ECS, event Event) (err error) {
+	defer recoverPanic(&err)
+	return s.handler(ecs, event)
+}
+
+// Calls the system's batch function, recovering from any panic as a PanicError
+func (s *system) callBatch(ecs *ECS, event Event, entities []Entity) (err error) {
+	defer recoverPanic(&err)
+	return s.batchF(ecs, event, entities)
 }
 
 // System is a wrapper around a function that only operates on entities with specific components
@@ -37,25 +297,82 @@ func (s System) ID() SystemID {
 	return s.id
 }
 
-// Func returns the system's function
+// Func returns the system's function, or nil if it was created with another kind of function
 func (s System) Func() SystemFunc {
 	return s.f
 }
 
-// TriggeredBy returns the event type the system should be triggered by
-func (s System) TriggeredBy() EventTypeID {
+// ErrFunc returns the system's function if it was created with an error returning function, or nil
+// otherwise
+func (s System) ErrFunc() SystemErrFunc {
+	return s.errF
+}
+
+// BatchFunc returns the system's function if it is a batch system, or nil otherwise
+func (s System) BatchFunc() BatchSystemFunc {
+	return s.batchF
+}
+
+// Handler returns the system's function if it was created with Subscribe, or nil otherwise
+func (s System) Handler() HandlerFunc {
+	return s.handler
+}
+
+// TriggeredBy returns the events the system should be triggered by
+func (s System) TriggeredBy() Trigger {
 	return s.triggeredBy
 }
 
 // ActsOn returns the components the system operates on
 func (s System) ActsOn() []ComponentTypeID {
-	var actsOn []ComponentTypeID
-	copy(actsOn, s.actsOn)
-	return actsOn
+	return s.query.Required()
+}
+
+// Query returns the query that selects the entities the system operates on
+func (s System) Query() Query {
+	return s.query
+}
+
+// Conditions returns the conditions that all have to be true for the system to run
+func (s System) Conditions() []RunCondition {
+	return append([]RunCondition(nil), s.conditions...)
+}
+
+// Stage returns the stage the system runs in
+func (s System) Stage() Stage {
+	return s.stage
+}
+
+// Before returns the systems the system has to run before
+func (s System) Before() []SystemID {
+	return append([]SystemID(nil), s.before...)
+}
+
+// Reads returns the component types the system declared it reads, or nil if it didn't declare its
+// access
+func (s System) Reads() []ComponentTypeID {
+	return accessTypes(s.access.reads)
+}
+
+// Writes returns the component types the system declared it writes, or nil if it didn't declare
+// its access
+func (s System) Writes() []ComponentTypeID {
+	return accessTypes(s.access.writes)
+}
+
+// ChunkSize returns the number of entities each goroutine running the system is given at a time, or
+// 0 if the system runs on one goroutine
+func (s System) ChunkSize() int {
+	return s.chunkSize
+}
+
+// LastRun returns the change tick the system last ran at, or 0 if it hasn't run
+func (s System) LastRun() uint64 {
+	return s.lastRun
 }
 
 // Entities returns the IDs of the entities the system thinks it should act on. Each entity should
-// contain a component of all the types returned by ActsOn
+// match the system's query
 func (s System) Entities() []EntityID {
 	entities := make([]EntityID, 0, len(s.entities))
 	for id := range s.entities {
@@ -70,6 +387,60 @@ type SystemManager interface {
 	// with all the given component types
 	NewSystem(SystemFunc, EventTypeID, []ComponentTypeID) SystemID
 
+	// NewSystemQuery creates a system that is triggered by the given event type and operates on
+	// entities matching the given query. Equivalent to NewSystem for:
+	//  NewQuery().With(actsOn...)
+	NewSystemQuery(SystemFunc, EventTypeID, Query) SystemID
+
+	// NewSystemErr creates a system like NewSystem, but with a function that can return an error.
+	// The errors are returned by RunSystems and RunSystemsParallel, as a SystemError
+	NewSystemErr(SystemErrFunc, EventTypeID, []ComponentTypeID) SystemID
+
+	// NewSystemQueryErr creates a system like NewSystemQuery, but with a function that can return
+	// an error
+	NewSystemQueryErr(SystemErrFunc, EventTypeID, Query) SystemID
+
+	// NewBatchSystem creates a system like NewSystem, but with a function that is called once for
+	// each event with all the entities the system operates on, even if there are none. Errors are
+	// returned as a SystemError without an entity (HasEntity is false)
+	NewBatchSystem(BatchSystemFunc, EventTypeID, []ComponentTypeID) SystemID
+
+	// NewBatchSystemQuery creates a system like NewBatchSystem, but with the entities selected by
+	// the given query
+	NewBatchSystemQuery(BatchSystemFunc, EventTypeID, Query) SystemID
+
+	// Subscribe creates a system that calls the handler once for each event of the given type,
+	// without any entities. The returned ID can be used to order the handler with other systems,
+	// and to unsubscribe it. Errors are returned as a SystemError without an entity (HasEntity is
+	// false)
+	Subscribe(EventTypeID, HandlerFunc) SystemID
+
+	// SubscribeFiltered subscribes the handler like Subscribe, but only for the events the filter
+	// returns true for
+	SubscribeFiltered(EventTypeID, func(Event) bool, HandlerFunc) SystemID
+
+	// Unsubscribe removes the handler. Equivalent to RemoveSystem
+	Unsubscribe(SystemID)
+
+	// NewCachedQuery creates a cached query, whose matching entities are kept up to date as
+	// components are created and deleted until it is closed
+	NewCachedQuery(Query) *CachedQuery
+
+	// RemoveSystem removes the system, so it doesn't run and isn't returned by ForSystems. Any order
+	// with other systems is removed too. The IDs of the other systems don't change, and the ID isn't
+	// reused. A system can remove itself while it runs, in which case it finishes running for the
+	// current event. Removing a system that has already been removed is a no-op
+	RemoveSystem(SystemID)
+
+	// SetSystemEnabled sets whether the system runs. Disabled systems keep their entities and order,
+	// so they can be enabled again later. This also enables systems disabled by ErrorDisable or a
+	// PanicHandler
+	SetSystemEnabled(id SystemID, enabled bool)
+
+	// IsSystemEnabled returns whether the system runs, which is false if it has been disabled or
+	// removed
+	IsSystemEnabled(SystemID) bool
+
 	// ForSystems calls the given iterator function on each system. If the iterator returns false
 	// or an error, the function will stop iterating (like a for loop break) and return the result
 	// of the iterator. Otherwise returns true, nil
@@ -78,23 +449,96 @@ type SystemManager interface {
 	// GetSystem returns the system
 	GetSystem(SystemID) System
 
-	// RunSystems runs the systems against the given event
-	RunSystems(Event)
+	// SetSystemStage moves the system to the given stage. Returns an error if this conflicts with
+	// the system's order, in which case the system isn't moved
+	SetSystemStage(SystemID, Stage) error
+
+	// SystemBefore makes the first system run before the second. Systems in the same stage run in
+	// the order they were created in unless they're ordered. Returns an error if this would create
+	// a cycle, or the first system is in a later stage, in which case the order isn't added
+	SystemBefore(SystemID, SystemID) error
+
+	// SystemAfter makes the first system run after the second. Equivalent to:
+	//  SystemBefore(second, first)
+	SystemAfter(SystemID, SystemID) error
+
+	// Schedule returns the IDs of the systems in the order they run in
+	Schedule() []SystemID
+
+	// SetSystemTrigger sets the events that trigger the system, for example several event types or
+	// every event
+	SetSystemTrigger(SystemID, Trigger)
+
+	// AddSystemCondition adds a condition that has to be true for the system to run for an event.
+	// The conditions are checked in the order they were added, until one is false
+	AddSystemCondition(SystemID, RunCondition)
+
+	// SetSystemAccess declares the component types the system reads and writes, including through
+	// other entities. RunSystemsParallel only runs systems at the same time if neither writes a
+	// type the other accesses. A system that hasn't declared its access doesn't run at the same
+	// time as any other system
+	SetSystemAccess(id SystemID, reads []ComponentTypeID, writes []ComponentTypeID)
+
+	// SetSystemChunkSize makes the system split its entities into chunks of the given size, which
+	// are run on up to GOMAXPROCS goroutines. The system function must be safe to call from
+	// multiple goroutines. A chunk size of 0 (the default) runs the system on one goroutine. Batch
+	// systems always run on one goroutine
+	SetSystemChunkSize(id SystemID, chunkSize int)
+
+	// SetAccessChecks sets whether systems that declared their access panic when they access a
+	// component type they didn't declare, which is returned as a PanicError. This is for debugging,
+	// as it makes systems slower
+	SetAccessChecks(bool)
 
-	// RunSystemsParallel runs the systems against the given event using goroutines
-	RunSystemsParallel(event Event)
+	// SetErrorPolicy sets what happens when a system returns an error. Panics in systems are
+	// recovered and handled like errors
+	SetErrorPolicy(ErrorPolicy)
+
+	// ErrorPolicy returns what happens when a system returns an error
+	ErrorPolicy() ErrorPolicy
+
+	// SetPanicHandler sets the function called when a system panics, for example to log the panic
+	// or quarantine the system. A nil handler (the default) only returns the panic as an error
+	SetPanicHandler(PanicHandler)
+
+	// RunSystems runs the systems against the given event, in the order of their schedule. Returns
+	// the errors from the systems, depending on the error policy. The structural changes the
+	// systems make are recorded in the engine's Commands, which Run applies (see ECS.Commands)
+	RunSystems(Event) error
+
+	// RunSystemsParallel runs the systems against the given event using goroutines. Systems only
+	// run at the same time as other systems in the same stage they aren't ordered with, and whose
+	// access doesn't conflict. Returns errors the same as RunSystems
+	RunSystemsParallel(event Event) error
 }
 
 type systemManager struct {
 	ecs     *ECS
 	systems []system
-	wg      sync.WaitGroup
+	// The systems in the order they run in, split into batches that can run at the same time
+	schedule [][]SystemID
+	// The batches of systems RunSystemsParallel runs for each event type, taking their access into
+	// account
+	parallel    map[EventTypeID][][]SystemID
+	checkAccess bool
+	errorPolicy ErrorPolicy
+	onPanic     PanicHandler
+	queries     map[*CachedQuery]struct{}
+	queryLock   sync.RWMutex
+	wg          sync.WaitGroup
 }
 
 func newSystemManager(ecs *ECS) *systemManager {
 	s := &systemManager{
-		ecs:     ecs,
-		systems: make([]system, 0),
+		ecs:      ecs,
+		systems:  make([]system, 0),
+		schedule: make([][]SystemID, 0),
+		parallel: make(map[EventTypeID][][]SystemID),
+		queries:  make(map[*CachedQuery]struct{}),
+	}
+	// The systems' structural changes are recorded in the engine's command buffer
+	if s.ecs.commands == nil {
+		s.ecs.commands = NewCommands(s.ecs.EntityComponentManager)
 	}
 	s.ecs.NewComponentCallback(s.newComponentCallback)
 	s.ecs.DeleteComponentCallback(s.deleteComponentCallback)
@@ -108,68 +552,344 @@ func NewSystemManager(ecs *ECS) SystemManager {
 
 func (m *systemManager) NewSystem(s SystemFunc,
 	triggeredBy EventTypeID, actsOn []ComponentTypeID) SystemID {
-	// Get all the entities the system should act on
-	entities := m.ecs.GetEntityIDs(actsOn)
+	return m.NewSystemQuery(s, triggeredBy, NewQuery().With(actsOn...))
+}
 
-	// Add the system
-	id := SystemID(len(m.systems))
-	m.systems = append(m.systems, system{
-		f:           s,
-		triggeredBy: triggeredBy,
-		actsOn:      actsOn,
-		entities:    make(map[EntityID]struct{}, len(entities)),
+func (m *systemManager) NewSystemQuery(s SystemFunc, triggeredBy EventTypeID, q Query) SystemID {
+	id := m.newSystem(triggeredBy, q)
+	m.systems[id].f = s
+	return id
+}
+
+func (m *systemManager) NewSystemErr(s SystemErrFunc,
+	triggeredBy EventTypeID, actsOn []ComponentTypeID) SystemID {
+	return m.NewSystemQueryErr(s, triggeredBy, NewQuery().With(actsOn...))
+}
+
+func (m *systemManager) NewSystemQueryErr(s SystemErrFunc, triggeredBy EventTypeID,
+	q Query) SystemID {
+	id := m.newSystem(triggeredBy, q)
+	m.systems[id].errF = s
+	return id
+}
+
+func (m *systemManager) NewBatchSystem(s BatchSystemFunc,
+	triggeredBy EventTypeID, actsOn []ComponentTypeID) SystemID {
+	return m.NewBatchSystemQuery(s, triggeredBy, NewQuery().With(actsOn...))
+}
+
+func (m *systemManager) NewBatchSystemQuery(s BatchSystemFunc, triggeredBy EventTypeID,
+	q Query) SystemID {
+	id := m.newSystem(triggeredBy, q)
+	m.systems[id].batchF = s
+	return id
+}
+
+func (m *systemManager) Subscribe(triggeredBy EventTypeID, handler HandlerFunc) SystemID {
+	return m.addSystem(system{
+		triggeredBy: TriggerOn(triggeredBy),
+		handler:     handler,
 	})
+}
+
+func (m *systemManager) SubscribeFiltered(triggeredBy EventTypeID, filter func(Event) bool,
+	handler HandlerFunc) SystemID {
+	id := m.Subscribe(triggeredBy, handler)
+	m.systems[id].triggeredBy = m.systems[id].triggeredBy.Filter(filter)
+	return id
+}
+
+func (m *systemManager) Unsubscribe(id SystemID) {
+	m.RemoveSystem(id)
+}
+
+// Adds a system without a function
+func (m *systemManager) newSystem(triggeredBy EventTypeID, q Query) SystemID {
+	// Get all the entities the system should act on
+	entities := m.ecs.QueryEntityIDs(q.withoutChangeFilters())
 
 	// Fill the set
+	set := make(map[EntityID]struct{}, len(entities))
 	for _, eID := range entities {
-		m.systems[id].entities[eID] = struct{}{}
+		set[eID] = struct{}{}
 	}
 
+	return m.addSystem(system{
+		triggeredBy: TriggerOn(triggeredBy),
+		query:       q,
+		entities:    set,
+	})
+}
+
+// Adds the system to the Update stage
+func (m *systemManager) addSystem(s system) SystemID {
+	id := SystemID(len(m.systems))
+	s.stage = Update
+	s.before = make([]SystemID, 0)
+	m.systems = append(m.systems, s)
+
+	// A new system isn't ordered, so this can't fail
+	_ = m.reschedule()
+
 	return id
 }
 
-func (m *systemManager) newComponentCallback(entity Entity) {
-	// Iterate over the systems
-	for _, system := range m.systems {
-		// Check if the entity has all the correct components
-		entityHasComponents := true
-		for _, cType := range system.actsOn {
-			if !entity.Has(cType) {
-				entityHasComponents = false
+// Resolves the schedule again, after the systems were changed. If the schedule can't be resolved,
+// the old schedule is kept
+func (m *systemManager) reschedule() error {
+	schedule, err := resolveSchedule(m.systems)
+	if err != nil {
+		return err
+	}
+	m.schedule = schedule
+	m.parallel = make(map[EventTypeID][][]SystemID)
+	return nil
+}
+
+func (m *systemManager) SetSystemStage(id SystemID, stage Stage) error {
+	old := m.systems[id].stage
+	m.systems[id].stage = stage
+	err := m.reschedule()
+	if err != nil {
+		m.systems[id].stage = old
+	}
+	return err
+}
+
+func (m *systemManager) SystemBefore(first, second SystemID) error {
+	if first == second {
+		return fmt.Errorf("system %d can't run before itself", first)
+	}
+	for _, id := range []SystemID{first, second} {
+		if m.systems[id].removed {
+			return fmt.Errorf("system %d has been removed", id)
+		}
+	}
+	old := m.systems[first].before
+	m.systems[first].before = append(append(make([]SystemID, 0, len(old)+1), old...), second)
+	err := m.reschedule()
+	if err != nil {
+		m.systems[first].before = old
+	}
+	return err
+}
+
+func (m *systemManager) SystemAfter(first, second SystemID) error {
+	return m.SystemBefore(second, first)
+}
+
+func (m *systemManager) RemoveSystem(id SystemID) {
+	s := &m.systems[id]
+	if s.removed {
+		return
+	}
+	// Keep the system's function, as the system may be removing itself while it runs
+	s.removed = true
+	s.entities = nil
+	s.before = nil
+	// Remove the system from the order of the other systems
+	for i := range m.systems {
+		before := m.systems[i].before
+		for j, other := range before {
+			if other == id {
+				m.systems[i].before = append(append(make([]SystemID, 0, len(before)-1),
+					before[:j]...), before[j+1:]...)
 				break
 			}
 		}
-		// If it does
-		if entityHasComponents {
-			// Add the entity to the system
-			system.entities[entity.ID()] = struct{}{}
+	}
+	// Removing a system can't create a cycle, so this can't fail
+	_ = m.reschedule()
+}
+
+func (m *systemManager) SetSystemEnabled(id SystemID, enabled bool) {
+	m.systems[id].disabled = !enabled
+}
+
+func (m *systemManager) IsSystemEnabled(id SystemID) bool {
+	return !m.systems[id].disabled && !m.systems[id].removed
+}
+
+func (m *systemManager) SetSystemTrigger(id SystemID, trigger Trigger) {
+	m.systems[id].triggeredBy = trigger
+	m.parallel = make(map[EventTypeID][][]SystemID)
+}
+
+func (m *systemManager) AddSystemCondition(id SystemID, condition RunCondition) {
+	m.systems[id].conditions = append(m.systems[id].conditions, condition)
+}
+
+func (m *systemManager) SetSystemAccess(id SystemID, reads []ComponentTypeID,
+	writes []ComponentTypeID) {
+	m.systems[id].access = newAccess(reads, writes)
+	m.parallel = make(map[EventTypeID][][]SystemID)
+}
+
+func (m *systemManager) SetSystemChunkSize(id SystemID, chunkSize int) {
+	m.systems[id].chunkSize = chunkSize
+}
+
+func (m *systemManager) SetErrorPolicy(policy ErrorPolicy) {
+	m.errorPolicy = policy
+}
+
+func (m *systemManager) ErrorPolicy() ErrorPolicy {
+	return m.errorPolicy
+}
+
+func (m *systemManager) SetPanicHandler(handler PanicHandler) {
+	m.onPanic = handler
+}
+
+func (m *systemManager) SetAccessChecks(checkAccess bool) {
+	m.checkAccess = checkAccess
+}
+
+// Returns the access checker for the system, or nil if it shouldn't be checked
+func (m *systemManager) checker(id SystemID) *accessChecker {
+	if !m.checkAccess || !m.systems[id].access.declared {
+		return nil
+	}
+	return &accessChecker{
+		id:     id,
+		access: m.systems[id].access,
+	}
+}
+
+// Returns how the system should be run
+func (m *systemManager) runOptions(id SystemID) runOptions {
+	return runOptions{
+		checker: m.checker(id),
+		policy:  m.errorPolicy,
+		onPanic: m.onPanic,
+	}
+}
+
+// Returns whether the first system has to run before the second, directly or through other systems
+func (m *systemManager) runsBefore(first, second SystemID) bool {
+	for _, id := range m.systems[first].before {
+		if id == second || m.runsBefore(id, second) {
+			return true
 		}
 	}
+	return false
 }
 
-func (m *systemManager) deleteComponentCallback(entity Entity) {
-	// Iterate over the systems
-	for _, system := range m.systems {
-		// Skip if the entity isn't in the system
-		_, ok := system.entities[entity.ID()]
-		if !ok {
+// Returns the batches of systems that may be triggered by the event type that can run at the same
+// time. Each system goes in the batch after the last system it is ordered after or conflicts with,
+// so conflicting systems keep the order of the schedule
+func (m *systemManager) parallelBatches(eType EventTypeID) [][]SystemID {
+	if batches, ok := m.parallel[eType]; ok {
+		return batches
+	}
+
+	batches := make([][]SystemID, 0)
+	// The current stage and its first batch
+	var stage Stage
+	stageStart := 0
+	// The triggered systems in the current stage that have been put in a batch
+	placed := make([]SystemID, 0)
+	batchOf := make(map[SystemID]int)
+	for i, id := range m.Schedule() {
+		s := &m.systems[id]
+		// Systems in a new stage have to wait for every batch of the previous stage
+		if i == 0 || s.stage != stage {
+			stage = s.stage
+			stageStart = len(batches)
+			placed = placed[:0]
+		}
+		if !s.triggeredBy.mayTrigger(eType) {
 			continue
 		}
 
-		// Check if the entity has all the correct components
-		entityHasComponents := true
-		for _, cType := range system.actsOn {
-			if !entity.Has(cType) {
-				entityHasComponents = false
-				break
+		batch := stageStart
+		for _, other := range placed {
+			if batchOf[other] >= batch &&
+				(m.runsBefore(other, id) || s.access.conflicts(m.systems[other].access)) {
+				batch = batchOf[other] + 1
 			}
 		}
-		// If it doesn't
-		if !entityHasComponents {
+		if batch == len(batches) {
+			batches = append(batches, make([]SystemID, 0))
+		}
+		batches[batch] = append(batches[batch], id)
+		batchOf[id] = batch
+		placed = append(placed, id)
+	}
+
+	m.parallel[eType] = batches
+	return batches
+}
+
+func (m *systemManager) Schedule() []SystemID {
+	schedule := make([]SystemID, 0, len(m.systems))
+	for _, batch := range m.schedule {
+		schedule = append(schedule, batch...)
+	}
+	return schedule
+}
+
+func (m *systemManager) NewCachedQuery(q Query) *CachedQuery {
+	cache := &CachedQuery{
+		query:    q,
+		ecs:      m.ecs,
+		entities: make(map[EntityID]struct{}),
+	}
+	cache.close = func() {
+		m.queryLock.Lock()
+		defer m.queryLock.Unlock()
+		delete(m.queries, cache)
+	}
+
+	// Register the cache before filling it, so components created in the meantime aren't missed
+	m.queryLock.Lock()
+	m.queries[cache] = struct{}{}
+	m.queryLock.Unlock()
+
+	for _, eID := range m.ecs.QueryEntityIDs(q.withoutChangeFilters()) {
+		entity, ok := m.ecs.GetEntitySafe(eID)
+		if ok {
+			cache.update(entity)
+		}
+	}
+
+	return cache
+}
+
+// Adds or removes the entity from each system, depending on whether it matches the system's query.
+// As queries can exclude component types, both adding and deleting a component can make an entity
+// start or stop matching
+func (m *systemManager) updateEntity(entity Entity) {
+	// Iterate over the systems
+	for _, system := range m.systems {
+		// Handlers don't have entities
+		if system.removed || system.handler != nil {
+			continue
+		}
+		// If the entity matches the query. The change filters are checked when the system runs
+		if system.query.matches(entity.Has) {
+			// Add the entity to the system
+			system.entities[entity.ID()] = struct{}{}
+		} else {
 			// Delete the entity from the system
 			delete(system.entities, entity.ID())
 		}
 	}
+
+	// And each cached query
+	m.queryLock.RLock()
+	defer m.queryLock.RUnlock()
+	for cache := range m.queries {
+		cache.update(entity)
+	}
+}
+
+func (m *systemManager) newComponentCallback(entity Entity) {
+	m.updateEntity(entity)
+}
+
+func (m *systemManager) deleteComponentCallback(entity Entity) {
+	m.updateEntity(entity)
 }
 
 func (m *systemManager) GetSystem(id SystemID) System {
@@ -181,6 +901,9 @@ func (m *systemManager) GetSystem(id SystemID) System {
 
 func (m *systemManager) ForSystems(i func(System) (bool, error)) (bool, error) {
 	for id, system := range m.systems {
+		if system.removed {
+			continue
+		}
 		ok, err := i(System{
 			id:     SystemID(id),
 			system: &system,
@@ -192,26 +915,59 @@ func (m *systemManager) ForSystems(i func(System) (bool, error)) (bool, error) {
 	return true, nil
 }
 
-func (m *systemManager) RunSystems(event Event) {
-	// Iterate over the systems
-	for _, system := range m.systems {
-		system.Run(m.ecs, event)
+func (m *systemManager) RunSystems(event Event) error {
+	errs := make([]error, 0)
+	// Advance the tick after the systems have run, so they see any changes made before they next
+	// run
+	defer m.ecs.AdvanceChangeTick()
+	// Iterate over the systems in order
+	for _, batch := range m.schedule {
+		for _, id := range batch {
+			opts := m.runOptions(id)
+			opts.tick = m.ecs.AdvanceChangeTick()
+			err := m.systems[id].Run(m.ecs, id, event, opts)
+			if err != nil {
+				if m.errorPolicy == ErrorStop {
+					return err
+				}
+				errs = append(errs, err)
+			}
+		}
 	}
+	return errors.Join(errs...)
 }
 
-func (m *systemManager) RunSystemsParallel(event Event) {
-	// Iterate over the systems
-	for _, system := range m.systems {
-		// If the system is triggered by the event
-		if system.triggeredBy == event.EventTypeID {
+func (m *systemManager) RunSystemsParallel(event Event) error {
+	lock := sync.Mutex{}
+	errs := make([]error, 0)
+	defer m.ecs.AdvanceChangeTick()
+	// Iterate over the batches of systems triggered by the event in order
+	for _, batch := range m.parallelBatches(event.EventTypeID) {
+		// The systems in the batch run at the same tick, so a system can't get a tick newer than
+		// its own changes from a system running at the same time
+		tick := m.ecs.AdvanceChangeTick()
+		for _, id := range batch {
+			system, opts := &m.systems[id], m.runOptions(id)
+			opts.tick = tick
 			m.wg.Add(1)
 			// Start a goroutine to run the system
 			go func() {
-				system.Run(m.ecs, event)
-				m.wg.Done()
+				defer m.wg.Done()
+				err := system.Run(m.ecs, id, event, opts)
+				if err != nil {
+					lock.Lock()
+					errs = append(errs, err)
+					lock.Unlock()
+				}
 			}()
 		}
+		// Wait for the batch to finish before starting the next one
+		m.wg.Wait()
+
+		// The systems in the batch have already run, so only the later batches can be stopped
+		if len(errs) > 0 && m.errorPolicy == ErrorStop {
+			break
+		}
 	}
-	// Wait for the goroutines to finish
-	m.wg.Wait()
+	return errors.Join(errs...)
 }
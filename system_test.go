@@ -2,6 +2,8 @@ package ecs
 
 import (
 	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -26,7 +28,7 @@ func TestSystemManager_NewSystem(t *testing.T) {
 	id := m.NewSystem(func(*ECS, Event, Entity) {}, EventType1,
 		[]ComponentTypeID{componentType1, componentType2})
 	a.Len(m.systems, 1)
-	a.Equal([]ComponentTypeID{componentType1, componentType2}, m.systems[id].actsOn)
+	a.Equal([]ComponentTypeID{componentType1, componentType2}, m.GetSystem(id).ActsOn())
 	a.Equal(map[EntityID]struct{}{
 		entityID1: {},
 	}, m.systems[id].entities)
@@ -90,4 +92,401 @@ func TestSystemManager_deleteComponentCallback(t *testing.T) {
 
 	ecs.DeleteComponent(componentID)
 	a.Equal(map[EntityID]struct{}{}, m.systems[id].entities)
+
+	// Deleting the entity should remove it from the system too
+	entityID2 := ecs.NewEntity("entity")
+	_, err = newComponent1(ecs, entityID2)
+	a.NoError(err)
+	_, err = newComponent2(ecs, entityID2)
+	a.NoError(err)
+	a.Equal(map[EntityID]struct{}{
+		entityID2: {},
+	}, m.systems[id].entities)
+
+	ecs.DeleteEntity(entityID2)
+	a.Equal(map[EntityID]struct{}{}, m.systems[id].entities)
+}
+
+func TestSystemManager_NewSystemQuery(t *testing.T) {
+	a := assert.New(t)
+	ecs := &ECS{
+		EntityComponentManager: NewEntityComponentManager(),
+	}
+	m := newSystemManager(ecs)
+
+	entityID1 := ecs.NewEntity("entity")
+	_, err := newComponent1(ecs, entityID1)
+	a.NoError(err)
+
+	entityID2 := ecs.NewEntity("entity")
+	_, err = newComponent1(ecs, entityID2)
+	a.NoError(err)
+	componentID, err := newComponent2(ecs, entityID2)
+	a.NoError(err)
+
+	q := NewQuery().With(componentType1).Without(componentType2)
+	id := m.NewSystemQuery(func(*ECS, Event, Entity) {}, EventType1, q)
+	a.Equal(q, m.GetSystem(id).Query())
+	a.Equal(map[EntityID]struct{}{
+		entityID1: {},
+	}, m.systems[id].entities)
+
+	// Adding an excluded component should remove the entity from the system
+	_, err = newComponent2(ecs, entityID1)
+	a.NoError(err)
+	a.Equal(map[EntityID]struct{}{}, m.systems[id].entities)
+
+	// Deleting an excluded component should add the entity to the system
+	ecs.DeleteComponent(componentID)
+	a.Equal(map[EntityID]struct{}{
+		entityID2: {},
+	}, m.systems[id].entities)
+}
+
+func TestSystemManager_ChangeFilters(t *testing.T) {
+	a := assert.New(t)
+	ecs := &ECS{
+		EntityComponentManager: NewEntityComponentManager(),
+	}
+	m := newSystemManager(ecs)
+
+	entityID1 := ecs.NewEntity("entity")
+	componentID1, err := newComponent1(ecs, entityID1)
+	a.NoError(err)
+
+	entityID2 := ecs.NewEntity("entity")
+	_, err = newComponent1(ecs, entityID2)
+	a.NoError(err)
+
+	ran := make([]EntityID, 0)
+	id := m.NewSystemQuery(func(ecs *ECS, _ Event, entity Entity) {
+		ran = append(ran, entity.ID())
+		// The system shouldn't see its own changes
+		entity.Get(componentType1).Update(ecs)
+	}, EventType1, NewQuery().Changed(componentType1))
+
+	// The first time the system runs, every component is new
+	m.RunSystems(Event{EventTypeID: EventType1})
+	a.ElementsMatch([]EntityID{entityID1, entityID2}, ran)
+	a.NotZero(m.GetSystem(id).LastRun())
+
+	ran = ran[:0]
+	m.RunSystems(Event{EventTypeID: EventType1})
+	a.Empty(ran)
+
+	// Changes made between runs should be seen
+	ecs.UpdateComponent(componentID1, 2)
+	m.RunSystems(Event{EventTypeID: EventType1})
+	a.Equal([]EntityID{entityID1}, ran)
+
+	ran = ran[:0]
+	m.RunSystems(Event{EventTypeID: EventType1})
+	a.Empty(ran)
+}
+
+func TestSystemManager_ChangeFiltersParallel(t *testing.T) {
+	a := assert.New(t)
+	ecs := &ECS{
+		EntityComponentManager: NewEntityComponentManager(),
+	}
+	m := newSystemManager(ecs)
+
+	entityID := ecs.NewEntity("entity")
+	_, err := newComponent1(ecs, entityID)
+	a.NoError(err)
+	_, err = newComponent2(ecs, entityID)
+	a.NoError(err)
+
+	// Two systems that run at the same time, each updating the component it filters on once both
+	// have started
+	var ran atomic.Int32
+	started := sync.WaitGroup{}
+	for _, cType := range []ComponentTypeID{componentType1, componentType2} {
+		id := m.NewSystemQuery(func(ecs *ECS, _ Event, entity Entity) {
+			ran.Add(1)
+			started.Done()
+			started.Wait()
+			entity.Get(cType).Update(ecs)
+		}, EventType1, NewQuery().Changed(cType))
+		m.SetSystemAccess(id, nil, []ComponentTypeID{cType})
+	}
+	a.Len(m.parallelBatches(EventType1), 1)
+
+	started.Add(2)
+	a.NoError(m.RunSystemsParallel(Event{EventTypeID: EventType1}))
+	a.Equal(int32(2), ran.Load())
+
+	// Neither system should see its own changes
+	a.NoError(m.RunSystemsParallel(Event{EventTypeID: EventType1}))
+	a.Equal(int32(2), ran.Load())
+}
+
+func TestSystemManager_SetSystemChunkSize(t *testing.T) {
+	testEntityComponentManagers(t, func(a *assert.Assertions, em EntityComponentManager) {
+		ecs := &ECS{
+			EntityComponentManager: em,
+		}
+		m := newSystemManager(ecs)
+
+		entities := make([]EntityID, 1000)
+		for i := range entities {
+			entities[i] = ecs.NewEntity("entity")
+			_, err := ecs.NewComponent(entities[i], componentType1, 0)
+			a.NoError(err)
+		}
+
+		id := m.NewSystem(func(_ *ECS, _ Event, entity Entity) {
+			Mutate(entity, func(count *int) {
+				*count++
+			})
+		}, EventType1, []ComponentTypeID{componentType1})
+		m.SetSystemChunkSize(id, 64)
+		a.Equal(64, m.GetSystem(id).ChunkSize())
+
+		// Every entity should be run exactly once
+		m.RunSystems(Event{EventTypeID: EventType1})
+		m.RunSystemsParallel(Event{EventTypeID: EventType1})
+		for _, entityID := range entities {
+			a.Equal(2, Get[int](ecs.GetEntity(entityID)))
+		}
+	})
+}
+
+func TestSystemManager_RemoveSystem(t *testing.T) {
+	a := assert.New(t)
+	m, ids, order := newScheduleTestSystems(3)
+
+	a.NoError(m.SystemBefore(ids[2], ids[1]))
+	m.RemoveSystem(ids[1])
+	m.RemoveSystem(ids[1])
+	a.False(m.IsSystemEnabled(ids[1]))
+	a.Equal([]SystemID{ids[0], ids[2]}, m.Schedule())
+	a.Empty(m.systems[ids[2]].before)
+	a.Error(m.SystemBefore(ids[0], ids[1]))
+
+	// The other systems should keep their IDs, and new systems shouldn't reuse the removed ID
+	id := m.NewSystem(func(*ECS, Event, Entity) {}, EventType1, []ComponentTypeID{componentType1})
+	a.Equal(SystemID(3), id)
+
+	systems := make([]SystemID, 0)
+	_, err := m.ForSystems(func(s System) (bool, error) {
+		systems = append(systems, s.ID())
+		return true, nil
+	})
+	a.NoError(err)
+	a.Equal([]SystemID{ids[0], ids[2], id}, systems)
+
+	// Removed systems shouldn't be run or updated with new entities
+	*order = (*order)[:0]
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType1}))
+	a.Equal([]SystemID{ids[0], ids[2]}, *order)
+	_, err = newComponent1(m.ecs, m.ecs.NewEntity("entity"))
+	a.NoError(err)
+	a.Empty(m.systems[ids[1]].entities)
+
+	// A system removing itself should finish running on its entities
+	ran := 0
+	id = m.NewSystem(func(*ECS, Event, Entity) {
+		ran++
+		m.RemoveSystem(id)
+	}, EventType2, []ComponentTypeID{componentType1})
+	entities := len(m.systems[id].entities)
+	a.Greater(entities, 1)
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType2}))
+	a.Equal(entities, ran)
+	a.False(m.IsSystemEnabled(id))
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType2}))
+	a.Equal(entities, ran)
+}
+
+func TestSystemManager_SetSystemEnabled(t *testing.T) {
+	a := assert.New(t)
+	m, ids, order := newScheduleTestSystems(2)
+
+	a.True(m.IsSystemEnabled(ids[0]))
+	m.SetSystemEnabled(ids[0], false)
+	a.False(m.IsSystemEnabled(ids[0]))
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType1}))
+	a.Equal([]SystemID{ids[1]}, *order)
+
+	// Disabled systems should keep their entities, so they run on them when enabled again
+	m.SetSystemEnabled(ids[0], true)
+	a.True(m.IsSystemEnabled(ids[0]))
+	*order = (*order)[:0]
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType1}))
+	a.Equal([]SystemID{ids[0], ids[1]}, *order)
+}
+
+func TestSystemManager_NewBatchSystem(t *testing.T) {
+	a := assert.New(t)
+	ecs := &ECS{
+		EntityComponentManager: NewEntityComponentManager(),
+	}
+	m := newSystemManager(ecs)
+
+	var batches [][]EntityID
+	id := m.NewBatchSystem(func(_ *ECS, _ Event, entities []Entity) error {
+		ids := make([]EntityID, 0, len(entities))
+		for _, entity := range entities {
+			ids = append(ids, entity.ID())
+		}
+		batches = append(batches, ids)
+		return nil
+	}, EventType1, []ComponentTypeID{componentType1})
+	a.NotNil(m.GetSystem(id).BatchFunc())
+	a.Nil(m.GetSystem(id).Func())
+
+	// The system should run even without any entities
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType1}))
+	a.Equal([][]EntityID{{}}, batches)
+
+	entityIDs := make([]EntityID, 3)
+	for i := range entityIDs {
+		entityIDs[i] = ecs.NewEntity("entity")
+		_, err := newComponent1(ecs, entityIDs[i])
+		a.NoError(err)
+	}
+	_, err := newComponent2(ecs, ecs.NewEntity("entity"))
+	a.NoError(err)
+
+	// The system should be called once with every entity, in order
+	batches = nil
+	a.NoError(m.RunSystemsParallel(Event{EventTypeID: EventType1}))
+	a.Equal([][]EntityID{entityIDs}, batches)
+
+	// Errors and panics should be returned like other systems
+	m.NewBatchSystem(func(*ECS, Event, []Entity) error {
+		return errTest
+	}, EventType1, []ComponentTypeID{componentType1})
+	m.NewBatchSystem(func(*ECS, Event, []Entity) error {
+		panic(errTest)
+	}, EventType1, []ComponentTypeID{componentType1})
+	err = m.RunSystems(Event{EventTypeID: EventType1})
+	a.ErrorIs(err, errTest)
+	var panicErr *PanicError
+	a.ErrorAs(err, &panicErr)
+
+	// The errors aren't for any one entity
+	var systemErr *SystemError
+	a.ErrorAs(err, &systemErr)
+	a.False(systemErr.HasEntity)
+}
+
+func TestSystemManager_Subscribe(t *testing.T) {
+	a := assert.New(t)
+	m, ids, ran := newScheduleTestSystems(1)
+
+	// Add more entities, which the handler shouldn't be called for
+	for i := 0; i < 3; i++ {
+		_, err := newComponent1(m.ecs, m.ecs.NewEntity("entity"))
+		a.NoError(err)
+	}
+
+	var events []Event
+	id := m.Subscribe(EventType1, func(_ *ECS, event Event) error {
+		events = append(events, event)
+		*ran = append(*ran, -1)
+		return nil
+	})
+	a.NotNil(m.GetSystem(id).Handler())
+	a.Empty(m.GetSystem(id).Entities())
+
+	// The handler should be called once, and can be ordered with other systems
+	a.NoError(m.SystemBefore(id, ids[0]))
+	*ran = (*ran)[:0]
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType1, Data: Event1Value}))
+	a.Equal([]Event{{EventTypeID: EventType1, Data: Event1Value}}, events)
+	a.Equal([]SystemID{-1, ids[0], ids[0], ids[0], ids[0]}, *ran)
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType2}))
+	a.Len(events, 1)
+
+	// Errors should be returned like other systems, but without an entity
+	failing := m.Subscribe(EventType1, func(*ECS, Event) error {
+		return errTest
+	})
+	err := m.RunSystemsParallel(Event{EventTypeID: EventType1})
+	a.ErrorIs(err, errTest)
+	var systemErr *SystemError
+	a.ErrorAs(err, &systemErr)
+	a.Equal(failing, systemErr.SystemID)
+	a.False(systemErr.HasEntity)
+
+	m.Unsubscribe(id)
+	m.Unsubscribe(failing)
+	events = nil
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType1}))
+	a.Empty(events)
+}
+
+func TestSystemManager_TargetedEvents(t *testing.T) {
+	a := assert.New(t)
+	ecs := &ECS{
+		EntityComponentManager: NewEntityComponentManager(),
+	}
+	m := newSystemManager(ecs)
+
+	entityIDs := make([]EntityID, 3)
+	for i := range entityIDs {
+		entityIDs[i] = ecs.NewEntity("entity")
+		_, err := newComponent1(ecs, entityIDs[i])
+		a.NoError(err)
+	}
+	other := ecs.NewEntity("other")
+
+	ran := make([]EntityID, 0)
+	m.NewSystem(func(_ *ECS, _ Event, entity Entity) {
+		ran = append(ran, entity.ID())
+	}, EventType1, []ComponentTypeID{componentType1})
+	var batch []Entity
+	m.NewBatchSystem(func(_ *ECS, _ Event, entities []Entity) error {
+		batch = entities
+		return nil
+	}, EventType1, []ComponentTypeID{componentType1})
+	handled := 0
+	m.Subscribe(EventType1, func(*ECS, Event) error {
+		handled++
+		return nil
+	})
+
+	// The systems should only run on the targets they act on, once each
+	a.NoError(m.RunSystems(Event{
+		EventTypeID: EventType1,
+		Targets:     []EntityID{entityIDs[2], other, entityIDs[0], entityIDs[2]},
+	}))
+	a.Equal([]EntityID{entityIDs[2], entityIDs[0]}, ran)
+	a.Len(batch, 2)
+	a.Equal(entityIDs[0], batch[0].ID())
+	a.Equal(entityIDs[2], batch[1].ID())
+	a.Equal(1, handled)
+
+	// An event without any targets shouldn't run on any entities
+	ran = ran[:0]
+	a.NoError(m.RunSystems(Event{
+		EventTypeID: EventType1,
+		Targets:     []EntityID{},
+	}))
+	a.Empty(ran)
+	a.Empty(batch)
+	a.Equal(2, handled)
+}
+
+func TestSystemManager_SubscribeFiltered(t *testing.T) {
+	a := assert.New(t)
+	ecs := &ECS{
+		EntityComponentManager: NewEntityComponentManager(),
+	}
+	m := newSystemManager(ecs)
+
+	events := make([]Event, 0)
+	m.SubscribeFiltered(EventType1, func(event Event) bool {
+		return event.Data == Event1Value
+	}, func(_ *ECS, event Event) error {
+		events = append(events, event)
+		return nil
+	})
+
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType1, Data: 2}))
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType2, Data: Event1Value}))
+	a.NoError(m.RunSystems(Event{EventTypeID: EventType1, Data: Event1Value}))
+	a.Equal([]Event{{EventTypeID: EventType1, Data: Event1Value}}, events)
 }
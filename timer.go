@@ -0,0 +1,126 @@
+package ecs
+
+import (
+	"time"
+)
+
+// TimerID is an identifier for a timer
+type TimerID uint64
+
+// Delay is how long a timer waits, either an amount of time or a number of ticks. The tick is
+// advanced each time the engine runs
+type Delay struct {
+	duration time.Duration
+	ticks    uint64
+	inTicks  bool
+}
+
+// Duration creates a delay of the given amount of time, measured by the event manager's clock
+func Duration(d time.Duration) Delay {
+	return Delay{
+		duration: d,
+	}
+}
+
+// Ticks creates a delay of the given number of ticks
+func Ticks(n uint64) Delay {
+	return Delay{
+		ticks:   n,
+		inTicks: true,
+	}
+}
+
+type timer struct {
+	id     TimerID
+	delay  Delay
+	repeat bool
+	event  Event
+	// When the timer is next due, depending on the type of delay
+	due     time.Time
+	dueTick uint64
+}
+
+// Returns whether the timer is due at the given time and tick
+func (t *timer) isDue(now time.Time, tick uint64) bool {
+	if t.delay.inTicks {
+		return tick >= t.dueTick
+	}
+	return !now.Before(t.due)
+}
+
+// Moves the timer to when it is next due
+func (t *timer) next() {
+	t.due = t.due.Add(t.delay.duration)
+	t.dueTick += t.delay.ticks
+}
+
+func (m *eventManager) newTimer(delay Delay, repeat bool,
+	eType EventTypeID, data interface{}, targets []EntityID) TimerID {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	event := Event{
+		EventTypeID: eType,
+		Data:        data,
+		Targets:     copyTargets(targets),
+	}
+
+	id := m.nextTimerID
+	m.nextTimerID++
+	m.timers = append(m.timers, &timer{
+		id:      id,
+		delay:   delay,
+		repeat:  repeat,
+		event:   event,
+		due:     m.clock.Now().Add(delay.duration),
+		dueTick: m.tick + delay.ticks,
+	})
+	return id
+}
+
+func (m *eventManager) NewEventAfter(delay Delay, eType EventTypeID, data interface{},
+	targets ...EntityID) TimerID {
+	return m.newTimer(delay, false, eType, data, targets)
+}
+
+func (m *eventManager) NewEventEvery(delay Delay, eType EventTypeID, data interface{},
+	targets ...EntityID) TimerID {
+	return m.newTimer(delay, true, eType, data, targets)
+}
+
+func (m *eventManager) CancelTimer(id TimerID) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for i, t := range m.timers {
+		if t.id == id {
+			m.timers = append(m.timers[:i], m.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *eventManager) AdvanceTimers() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.tick++
+	now := m.clock.Now()
+
+	// Fire the timers that are due in the order they were created, and keep the ones that aren't
+	// finished
+	remaining := m.timers[:0]
+	for _, t := range m.timers {
+		if !t.isDue(now, m.tick) {
+			remaining = append(remaining, t)
+			continue
+		}
+		m.push(t.event)
+		if t.repeat {
+			t.next()
+			remaining = append(remaining, t)
+		}
+	}
+	clear(m.timers[len(remaining):])
+	m.timers = remaining
+}
@@ -0,0 +1,89 @@
+package ecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEventManager_NewEventAfter(t *testing.T) {
+	a := assert.New(t)
+	clock := NewManualClock(time.Unix(0, 0))
+	m := NewEventManagerWithClock(clock).(*eventManager)
+
+	m.NewEventAfter(Duration(time.Second), EventType1, 1)
+	m.NewEventAfter(Ticks(2), EventType1, 2, 5)
+	cancelled := m.NewEventAfter(Ticks(1), EventType1, 3)
+	a.True(m.CancelTimer(cancelled))
+	a.False(m.CancelTimer(cancelled))
+
+	m.AdvanceTimers()
+	a.Len(m.TakeEvents(), 0)
+
+	m.AdvanceTimers()
+	a.Equal([]Event{{EventTypeID: EventType1, Data: 2, Targets: []EntityID{5}}}, m.TakeEvents())
+
+	// Without targets the event should be for every entity
+	clock.Advance(time.Second)
+	m.AdvanceTimers()
+	a.Equal([]Event{{EventTypeID: EventType1, Data: 1}}, m.TakeEvents())
+
+	// The timers should only fire once
+	clock.Advance(time.Second)
+	m.AdvanceTimers()
+	a.Len(m.TakeEvents(), 0)
+	a.Len(m.timers, 0)
+}
+
+func TestEventManager_NewEventEvery(t *testing.T) {
+	a := assert.New(t)
+	clock := NewManualClock(time.Unix(0, 0))
+	m := NewEventManagerWithClock(clock).(*eventManager)
+
+	byTime := m.NewEventEvery(Duration(time.Second), EventType1, 1)
+	byTicks := m.NewEventEvery(Ticks(2), EventType1, 2)
+
+	counts := make(map[interface{}]int)
+	for i := 0; i < 4; i++ {
+		clock.Advance(time.Second)
+		m.AdvanceTimers()
+		for _, event := range m.TakeEvents() {
+			counts[event.Data]++
+		}
+	}
+	a.Equal(map[interface{}]int{1: 4, 2: 2}, counts)
+
+	// Missed events should be caught up on, one per call
+	clock.Advance(2 * time.Second)
+	a.True(m.CancelTimer(byTicks))
+	m.AdvanceTimers()
+	a.Len(m.TakeEvents(), 1)
+	m.AdvanceTimers()
+	a.Len(m.TakeEvents(), 1)
+	m.AdvanceTimers()
+	a.Len(m.TakeEvents(), 0)
+
+	a.True(m.CancelTimer(byTime))
+	a.Len(m.timers, 0)
+}
+
+func TestECS_Timers(t *testing.T) {
+	a := assert.New(t)
+	clock := NewManualClock(time.Unix(0, 0))
+	ecs := New(WithTimerClock(clock))
+
+	_, err := newComponent1(ecs, ecs.NewEntity("entity"))
+	a.NoError(err)
+	ran := 0
+	ecs.NewSystem(func(*ECS, Event, Entity) {
+		ran++
+	}, EventType1, []ComponentTypeID{componentType1})
+
+	// The timer should be advanced by Run
+	ecs.NewEventAfter(Duration(time.Second), EventType1, Event1Value)
+	a.NoError(ecs.Run())
+	a.Equal(0, ran)
+	clock.Advance(time.Second)
+	a.NoError(ecs.Run())
+	a.Equal(1, ran)
+}
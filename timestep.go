@@ -0,0 +1,165 @@
+package ecs
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Clock is a source of the current time
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock returns a clock that uses the system time
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// ManualClock is a clock that only changes when it is advanced, for example for tests. It is safe
+// to use from multiple goroutines
+type ManualClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+// NewManualClock creates and returns a clock starting at the given time
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{
+		now: now,
+	}
+}
+
+// Now returns the clock's time
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// Set sets the clock's time
+func (c *ManualClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock's time forward by the given duration
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// DefaultMaxCatchUp is the default maximum amount of time a Timestep catches up on in one Tick
+const DefaultMaxCatchUp = time.Second
+
+// Timestep creates update events at a fixed rate, and render events at a variable rate (once per
+// Tick). This keeps the updates the same however fast the game is rendered, with the render events
+// given how far through the next step the time is so they can interpolate between steps
+type Timestep struct {
+	step       time.Duration
+	maxCatchUp time.Duration
+	clock      Clock
+	update     func(step time.Duration) Event
+	render     func(alpha float64) Event
+
+	prev time.Time
+	// The time that hasn't been stepped through yet
+	lag   time.Duration
+	alpha float64
+}
+
+// TimestepOption is an option for creating a Timestep with NewTimestep
+type TimestepOption func(*Timestep)
+
+// WithClock makes the timestep use the given clock instead of the system time
+func WithClock(clock Clock) TimestepOption {
+	return func(t *Timestep) {
+		t.clock = clock
+	}
+}
+
+// WithMaxCatchUp sets the maximum amount of time the timestep catches up on in one Tick, for
+// example after the game was paused by the OS. Any more time is dropped, so the game slows down
+// rather than creating more updates than it can handle. A duration less than the step is raised to
+// the step, so there is always time for one update. Panics if the duration isn't positive
+func WithMaxCatchUp(d time.Duration) TimestepOption {
+	if d <= 0 {
+		panic(fmt.Errorf("max catch up must be positive, got %s", d))
+	}
+	return func(t *Timestep) {
+		t.maxCatchUp = d
+	}
+}
+
+// NewTimestep creates a timestep that creates an update event every step, using the update
+// function, and a render event every Tick, using the render function. The render function is given
+// the interpolation alpha, which is how far through the next step the time is from 0 to 1. If the
+// render function is nil no render events are created. The time starts when the timestep is created.
+// Panics if the step isn't positive
+func NewTimestep(step time.Duration, update func(step time.Duration) Event,
+	render func(alpha float64) Event, options ...TimestepOption) *Timestep {
+	if step <= 0 {
+		panic(fmt.Errorf("step must be positive, got %s", step))
+	}
+	t := &Timestep{
+		step:       step,
+		maxCatchUp: DefaultMaxCatchUp,
+		clock:      SystemClock(),
+		update:     update,
+		render:     render,
+	}
+	for _, option := range options {
+		option(t)
+	}
+	// Otherwise the lag would never reach a step, and no updates would be created
+	if t.maxCatchUp < t.step {
+		t.maxCatchUp = t.step
+	}
+	t.prev = t.clock.Now()
+	return t
+}
+
+// Tick creates an update event for each step that has passed since the last Tick, followed by a
+// render event. Returns the number of update events created. The timestep isn't safe to use from
+// multiple goroutines
+func (t *Timestep) Tick(m EventManager) int {
+	now := t.clock.Now()
+	t.lag += now.Sub(t.prev)
+	t.prev = now
+	if t.lag > t.maxCatchUp {
+		t.lag = t.maxCatchUp
+	}
+
+	updates := 0
+	for t.lag >= t.step {
+		event := t.update(t.step)
+		m.NewEventFor(event.EventTypeID, event.Data, event.Targets...)
+		t.lag -= t.step
+		updates++
+	}
+
+	t.alpha = float64(t.lag) / float64(t.step)
+	if t.render != nil {
+		event := t.render(t.alpha)
+		m.NewEventFor(event.EventTypeID, event.Data, event.Targets...)
+	}
+	return updates
+}
+
+// Step returns the time between update events
+func (t *Timestep) Step() time.Duration {
+	return t.step
+}
+
+// Alpha returns the interpolation alpha from the last Tick
+func (t *Timestep) Alpha() float64 {
+	return t.alpha
+}
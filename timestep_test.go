@@ -0,0 +1,101 @@
+package ecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	a := assert.New(t)
+	start := time.Unix(0, 0)
+	clock := NewManualClock(start)
+
+	a.Equal(start, clock.Now())
+	clock.Advance(time.Second)
+	a.Equal(start.Add(time.Second), clock.Now())
+}
+
+func TestTimestep_Tick(t *testing.T) {
+	a := assert.New(t)
+	clock := NewManualClock(time.Unix(0, 0))
+	m := newEventManager()
+
+	timestep := NewTimestep(10*time.Millisecond, func(step time.Duration) Event {
+		return Event{EventTypeID: EventType1, Data: int(step.Milliseconds())}
+	}, func(alpha float64) Event {
+		return Event{EventTypeID: EventType2, Data: "render"}
+	}, WithClock(clock), WithMaxCatchUp(100*time.Millisecond))
+	a.Equal(10*time.Millisecond, timestep.Step())
+
+	for _, test := range []struct {
+		advance time.Duration
+		updates int
+		alpha   float64
+	}{
+		// Nothing has passed, but the render event should still be created
+		{0, 0, 0},
+		{5 * time.Millisecond, 0, 0.5},
+		// The left over time should be carried over
+		{17 * time.Millisecond, 2, 0.2},
+		{8 * time.Millisecond, 1, 0},
+		// The time caught up on should be capped
+		{time.Second, 10, 0},
+	} {
+		clock.Advance(test.advance)
+		a.Equal(test.updates, timestep.Tick(m))
+		a.InDelta(test.alpha, timestep.Alpha(), 1e-9)
+
+		events := m.TakeEvents()
+		a.Len(events, test.updates+1)
+		for _, event := range events[:test.updates] {
+			a.Equal(Event{EventTypeID: EventType1, Data: 10}, event)
+		}
+		a.Equal(Event{EventTypeID: EventType2, Data: "render"}, events[test.updates])
+	}
+
+	// A step that isn't positive would never finish catching up
+	for _, step := range []time.Duration{0, -time.Millisecond} {
+		a.Panics(func() {
+			NewTimestep(step, nil, nil)
+		})
+	}
+	a.Panics(func() {
+		WithMaxCatchUp(0)
+	})
+}
+
+func TestTimestep_MaxCatchUpLessThanStep(t *testing.T) {
+	a := assert.New(t)
+	clock := NewManualClock(time.Unix(0, 0))
+	m := newEventManager()
+
+	// The max catch up should be raised to the step, otherwise there would never be an update
+	timestep := NewTimestep(time.Second, func(time.Duration) Event {
+		return Event{EventTypeID: EventType1, Data: 1}
+	}, nil, WithClock(clock), WithMaxCatchUp(time.Millisecond))
+
+	clock.Advance(2 * time.Second)
+	a.Equal(1, timestep.Tick(m))
+	a.Len(m.TakeEvents(), 1)
+}
+
+func TestTimestep_Targets(t *testing.T) {
+	a := assert.New(t)
+	clock := NewManualClock(time.Unix(0, 0))
+	m := newEventManager()
+
+	timestep := NewTimestep(time.Second, func(time.Duration) Event {
+		return Event{EventTypeID: EventType1, Data: 1, Targets: []EntityID{1, 2}}
+	}, func(float64) Event {
+		return Event{EventTypeID: EventType2, Data: "render", Targets: []EntityID{3}}
+	}, WithClock(clock))
+
+	// The events should keep their targets
+	clock.Advance(time.Second)
+	a.Equal(1, timestep.Tick(m))
+	a.Equal([]Event{
+		{EventTypeID: EventType1, Data: 1, Targets: []EntityID{1, 2}},
+		{EventTypeID: EventType2, Data: "render", Targets: []EntityID{3}},
+	}, m.TakeEvents())
+}
@@ -0,0 +1,90 @@
+package ecs
+
+// Trigger selects the events a system is triggered by. Systems created with NewSystem are
+// triggered by a single event type, which can be changed with SetSystemTrigger
+type Trigger struct {
+	eventTypes map[EventTypeID]struct{}
+	all        bool
+	f          func(Event) bool
+}
+
+// TriggerOn creates a trigger for events of any of the given types
+func TriggerOn(eventTypes ...EventTypeID) Trigger {
+	t := Trigger{
+		eventTypes: make(map[EventTypeID]struct{}, len(eventTypes)),
+	}
+	for _, eType := range eventTypes {
+		t.eventTypes[eType] = struct{}{}
+	}
+	return t
+}
+
+// TriggerOnAll creates a trigger for every event
+func TriggerOnAll() Trigger {
+	return Trigger{
+		all: true,
+	}
+}
+
+// TriggerOnFunc creates a trigger for the events the given function returns true for. The function
+// can be called from multiple goroutines at once, and panics in it are recovered like panics in the
+// system
+func TriggerOnFunc(f func(Event) bool) Trigger {
+	return Trigger{
+		f: f,
+	}
+}
+
+// Filter returns a copy of the trigger that is also only for the events the given function returns
+// true for. The function can be called from multiple goroutines at once
+func (t Trigger) Filter(f func(Event) bool) Trigger {
+	if prev := t.f; prev != nil {
+		t.f = func(event Event) bool {
+			return prev(event) && f(event)
+		}
+	} else {
+		t.f = f
+	}
+	return t
+}
+
+// Triggers returns whether the event triggers the system
+func (t Trigger) Triggers(event Event) bool {
+	if !t.mayTrigger(event.EventTypeID) {
+		return false
+	}
+	return t.f == nil || t.f(event)
+}
+
+// Returns whether an event of the given type could trigger the system, depending on the function
+// if there is one
+func (t Trigger) mayTrigger(eType EventTypeID) bool {
+	if t.all || t.eventTypes == nil {
+		return true
+	}
+	_, ok := t.eventTypes[eType]
+	return ok
+}
+
+// EventTypes returns the event types the trigger is for, or nil if it is for every event or only
+// uses a function
+func (t Trigger) EventTypes() []EventTypeID {
+	if t.eventTypes == nil {
+		return nil
+	}
+	eventTypes := make([]EventTypeID, 0, len(t.eventTypes))
+	for eType := range t.eventTypes {
+		eventTypes = append(eventTypes, eType)
+	}
+	return eventTypes
+}
+
+// All returns whether the trigger is for every event
+func (t Trigger) All() bool {
+	return t.all
+}
+
+// Func returns the function the trigger uses, or nil if it doesn't use one
+func (t Trigger) Func() func(Event) bool {
+	return t.f
+}
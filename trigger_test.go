@@ -0,0 +1,103 @@
+package ecs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+var (
+	EventType2 = reflect.TypeOf((*string)(nil)).Elem()
+	EventType3 = reflect.TypeOf((*bool)(nil)).Elem()
+)
+
+func TestTrigger(t *testing.T) {
+	a := assert.New(t)
+	event1 := Event{EventTypeID: EventType1, Data: Event1Value}
+	event2 := Event{EventTypeID: EventType2, Data: "event"}
+
+	trigger := TriggerOn(EventType1, EventType2)
+	a.True(trigger.Triggers(event1))
+	a.True(trigger.Triggers(event2))
+	a.False(trigger.Triggers(Event{EventTypeID: EventType3}))
+	a.ElementsMatch([]EventTypeID{EventType1, EventType2}, trigger.EventTypes())
+	a.False(trigger.All())
+	a.Nil(trigger.Func())
+
+	trigger = TriggerOnAll()
+	a.True(trigger.Triggers(event1))
+	a.True(trigger.Triggers(Event{EventTypeID: EventType3}))
+	a.Nil(trigger.EventTypes())
+	a.True(trigger.All())
+
+	trigger = TriggerOnFunc(func(event Event) bool {
+		return event.Data == Event1Value
+	})
+	a.True(trigger.Triggers(event1))
+	a.False(trigger.Triggers(event2))
+	a.True(trigger.mayTrigger(EventType2))
+	a.Nil(trigger.EventTypes())
+	a.NotNil(trigger.Func())
+
+	// Filters should apply on top of the event types and any other filter
+	trigger = TriggerOn(EventType1, EventType2).Filter(func(event Event) bool {
+		return event.Data != Event1Value
+	})
+	a.False(trigger.Triggers(event1))
+	a.True(trigger.Triggers(event2))
+	a.False(trigger.Triggers(Event{EventTypeID: EventType3}))
+	trigger = trigger.Filter(func(event Event) bool {
+		return event.Data != "event"
+	})
+	a.False(trigger.Triggers(event2))
+	a.True(trigger.Triggers(Event{EventTypeID: EventType2, Data: "other"}))
+}
+
+func TestSystemManager_SetSystemTrigger(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		t.Run(map[bool]string{false: "serial", true: "parallel"}[parallel], func(t *testing.T) {
+			a := assert.New(t)
+			m, ids, ran := newScheduleTestSystems(3)
+			run := m.RunSystems
+			if parallel {
+				run = m.RunSystemsParallel
+			}
+
+			m.SetSystemTrigger(ids[0], TriggerOn(EventType1, EventType2))
+			m.SetSystemTrigger(ids[1], TriggerOnAll())
+			m.SetSystemTrigger(ids[2], TriggerOnFunc(func(event Event) bool {
+				return event.Data == "trigger"
+			}))
+			a.ElementsMatch([]EventTypeID{EventType1, EventType2},
+				m.GetSystem(ids[0]).TriggeredBy().EventTypes())
+
+			for _, test := range []struct {
+				event Event
+				ran   []SystemID
+			}{
+				{Event{EventTypeID: EventType1, Data: Event1Value}, []SystemID{ids[0], ids[1]}},
+				{Event{EventTypeID: EventType2, Data: "trigger"}, ids},
+				{Event{EventTypeID: EventType3}, []SystemID{ids[1]}},
+			} {
+				*ran = (*ran)[:0]
+				a.NoError(run(test.event))
+				a.Equal(test.ran, *ran)
+			}
+
+			// A panicking trigger function should be returned as an error
+			m.SetSystemTrigger(ids[2], TriggerOn(EventType1).Filter(func(Event) bool {
+				panic("trigger")
+			}))
+			*ran = (*ran)[:0]
+			err := run(Event{EventTypeID: EventType1})
+			var systemErr *SystemError
+			a.ErrorAs(err, &systemErr)
+			a.Equal(ids[2], systemErr.SystemID)
+			a.False(systemErr.HasEntity)
+			var panicErr *PanicError
+			a.ErrorAs(err, &panicErr)
+			a.Equal("trigger", panicErr.Value)
+			a.Equal([]SystemID{ids[0], ids[1]}, *ran)
+		})
+	}
+}